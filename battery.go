@@ -0,0 +1,94 @@
+package meterstanden
+
+import (
+	"io"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// BatteryReadout represents a readout from a battery that is connected to the inverter (SolarEdge StorEdge)
+type BatteryReadout struct {
+	Timestamp       time.Time `json:"time,omitempty"`
+	Power           int64     `json:"power,omitempty"`           // W, positive while charging, negative while discharging
+	Voltage         int64     `json:"voltage,omitempty"`         // mV
+	Current         int64     `json:"current,omitempty"`         // mA
+	Temperature     int64     `json:"temperature,omitempty"`     // cC
+	MaxTemperature  int64     `json:"maxTemperature,omitempty"`  // cC
+	StateOfCharge   int64     `json:"stateOfCharge,omitempty"`   // c%
+	StateOfHealth   int64     `json:"stateOfHealth,omitempty"`   // c%
+	AvailableEnergy int64     `json:"availableEnergy,omitempty"` // Wh
+	MaxEnergy       int64     `json:"maxEnergy,omitempty"`       // Wh
+	Charged         int64     `json:"charged,omitempty"`         // Wh, lifetime
+	Discharged      int64     `json:"discharged,omitempty"`      // Wh, lifetime
+	Status          int64     `json:"status,omitempty"`
+
+	// The source tag and the serial number of the battery, they are only written to Influx
+	Device string `json:"device,omitempty"`
+	Serial string `json:"serial,omitempty"`
+}
+
+type BatteryReadoutHandler struct {
+	IMeasurementHandler[BatteryReadout]
+	Device string
+}
+
+func (h BatteryReadoutHandler) Name() string {
+	return deviceStream("battery", h.Device)
+}
+
+func (h BatteryReadoutHandler) CreatePoint(m BatteryReadout) *write.Point {
+	return influxdb2.NewPoint(
+		"battery",
+		deviceTags(m.Device, m.Serial, "solar-edge-battery-1"),
+		map[string]interface{}{
+			"power":           float64(m.Power),
+			"voltage":         float64(m.Voltage) / 1000.0,
+			"current":         float64(m.Current) / 1000.0,
+			"temperature":     float64(m.Temperature) / 100.0,
+			"maxTemperature":  float64(m.MaxTemperature) / 100.0,
+			"stateOfCharge":   float64(m.StateOfCharge) / 100.0,
+			"stateOfHealth":   float64(m.StateOfHealth) / 100.0,
+			"availableEnergy": float64(m.AvailableEnergy),
+			"maxEnergy":       float64(m.MaxEnergy),
+			"charged":         float64(m.Charged),
+			"discharged":      float64(m.Discharged),
+			"status":          m.Status,
+		},
+		m.Timestamp,
+	)
+}
+
+func (h BatteryReadoutHandler) GetTimestamp(m BatteryReadout) time.Time {
+	return m.Timestamp
+}
+
+func (h BatteryReadoutHandler) WriteMeasurement(writer io.Writer, m BatteryReadout, previous BatteryReadout) error {
+	if err := WriteValue(writer, m.Timestamp.Unix(), previous.Timestamp.Unix()); err != nil {
+		return err
+	}
+	for _, values := range [][2]int64{
+		{m.Power, previous.Power},
+		{m.Voltage, previous.Voltage},
+		{m.Current, previous.Current},
+		{m.Temperature, previous.Temperature},
+		{m.MaxTemperature, previous.MaxTemperature},
+		{m.StateOfCharge, previous.StateOfCharge},
+		{m.StateOfHealth, previous.StateOfHealth},
+		{m.AvailableEnergy, previous.AvailableEnergy},
+		{m.MaxEnergy, previous.MaxEnergy},
+		{m.Charged, previous.Charged},
+		{m.Discharged, previous.Discharged},
+		{m.Status, previous.Status},
+	} {
+		if err := WriteValue(writer, values[0], values[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h BatteryReadoutHandler) ZeroMeasurement() BatteryReadout {
+	return BatteryReadout{Timestamp: time.Unix(0, 0)}
+}
@@ -0,0 +1,254 @@
+// Package bus shares a Modbus connection between the devices (units) that are reached through it, e.g. the slaves on
+// an RS-485 bus or the devices behind a TCP gateway
+package bus
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/simonvetter/modbus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Bus is a Modbus connection that is shared by its units. The requests of the units are serialized, the connection is
+// opened on the first request and reopened after a transport error.
+//
+// The requests and errors are counted in the metrics: `modbus_requests`, `modbus_errors` (all failed requests),
+// `modbus_crc_errors`, `modbus_timeouts` and `modbus_exceptions` (errors reported by the device).
+type Bus struct {
+	name    string
+	conf    modbus.ClientConfiguration
+	metrics *smr.Metrics
+
+	mu     sync.Mutex
+	client *modbus.ModbusClient
+	open   bool
+}
+
+var (
+	busesMu sync.Mutex
+	buses   = map[string]*Bus{}
+)
+
+// Open returns the bus of the url and the unit ids that are given in the url. The url is either
+// `tcp://host:port?unit_id=1` or `rtu:///dev/ttyUSB0?baud=9600&parity=even&stop_bits=1&unit_id=1,2`, the other
+// schemes of github.com/simonvetter/modbus are supported as well. Multiple unit ids are separated by commas, the unit
+// id is 1 by default. The buses are shared: opening the same host or serial port again returns the same bus, its
+// options must then be the same.
+func Open(rawurl string, timeout time.Duration, metrics *smr.Metrics) (*Bus, []uint8, error) {
+	conf, units, err := parse(rawurl)
+	if err != nil {
+		return nil, nil, err
+	}
+	conf.Timeout = timeout
+
+	busesMu.Lock()
+	defer busesMu.Unlock()
+
+	if b, ok := buses[conf.URL]; ok {
+		if b.conf != conf {
+			return nil, nil, fmt.Errorf("%s is already opened with other options", conf.URL)
+		}
+		return b, units, nil
+	}
+
+	client, err := modbus.NewClient(&conf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create a client for %s: %w", conf.URL, err)
+	}
+	b := &Bus{name: conf.URL, conf: conf, metrics: metrics, client: client}
+	buses[conf.URL] = b
+	return b, units, nil
+}
+
+// parse returns the configuration of the client and the unit ids of the url
+func parse(rawurl string) (modbus.ClientConfiguration, []uint8, error) {
+	conf := modbus.ClientConfiguration{}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return conf, nil, err
+	}
+	if u.Scheme == "" {
+		return conf, nil, fmt.Errorf("missing scheme in '%s'", rawurl)
+	}
+	// The client does not accept query parameters, they are removed from the url
+	conf.URL = u.Scheme + "://" + u.Host + u.Path
+
+	query := u.Query()
+	if baud := query.Get("baud"); baud != "" {
+		speed, err := strconv.ParseUint(baud, 10, 32)
+		if err != nil {
+			return conf, nil, fmt.Errorf("could not parse baud '%s'", baud)
+		}
+		conf.Speed = uint(speed)
+	}
+
+	if dataBits := query.Get("data_bits"); dataBits != "" {
+		bits, err := strconv.ParseUint(dataBits, 10, 8)
+		if err != nil || bits < 5 || bits > 8 {
+			return conf, nil, fmt.Errorf("could not parse data_bits '%s'", dataBits)
+		}
+		conf.DataBits = uint(bits)
+	}
+
+	// The parity is given by its name or its first letter, e.g. `even` or `E`
+	if parity := query.Get("parity"); parity != "" {
+		switch strings.ToUpper(parity)[0] {
+		case 'N':
+			conf.Parity = modbus.PARITY_NONE
+		case 'E':
+			conf.Parity = modbus.PARITY_EVEN
+		case 'O':
+			conf.Parity = modbus.PARITY_ODD
+		default:
+			return conf, nil, fmt.Errorf("could not parse parity '%s'", parity)
+		}
+	}
+
+	// Without stop bits the client uses 2 stop bits without parity and 1 stop bit with parity, as the standard
+	// prescribes
+	switch stopBits := query.Get("stop_bits"); stopBits {
+	case "":
+	case "1":
+		conf.StopBits = 1
+	case "2":
+		conf.StopBits = 2
+	default:
+		return conf, nil, fmt.Errorf("could not parse stop_bits '%s'", stopBits)
+	}
+
+	units := []uint8{1}
+	if ids := query.Get("unit_id"); ids != "" {
+		units = nil
+		for _, id := range strings.Split(ids, ",") {
+			unit, err := strconv.ParseUint(strings.TrimSpace(id), 10, 8)
+			if err != nil {
+				return conf, nil, fmt.Errorf("could not parse unit_id '%s'", ids)
+			}
+			units = append(units, uint8(unit))
+		}
+	}
+	return conf, units, nil
+}
+
+// Name returns the url of the bus without its options
+func (b *Bus) Name() string {
+	return b.name
+}
+
+// Unit returns the device with the given unit id
+func (b *Bus) Unit(id uint8) *Unit {
+	return &Unit{bus: b, id: id}
+}
+
+// do performs a request for the unit while holding the bus
+func (b *Bus) do(id uint8, request func(client *modbus.ModbusClient) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		if err := b.client.Open(); err != nil {
+			b.metrics.Add("modbus_errors", 1)
+			return fmt.Errorf("could not open %s: %w", b.name, err)
+		}
+		b.open = true
+	}
+	b.client.SetUnitId(id)
+
+	b.metrics.Add("modbus_requests", 1)
+	err := request(b.client)
+	if err == nil {
+		return nil
+	}
+
+	b.metrics.Add("modbus_errors", 1)
+	switch {
+	case isException(err):
+		// The device answered, so the connection is fine
+		b.metrics.Add("modbus_exceptions", 1)
+		return err
+	case errors.Is(err, modbus.ErrBadCRC):
+		b.metrics.Add("modbus_crc_errors", 1)
+	case errors.Is(err, modbus.ErrRequestTimedOut):
+		b.metrics.Add("modbus_timeouts", 1)
+	}
+
+	// After a transport error the state of the connection is unknown, e.g. a late answer could be taken for the answer
+	// to the next request, so it is reopened
+	log.Debugf("Closing %s after error of unit %d: %v", b.name, id, err)
+	b.client.Close()
+	b.open = false
+	return err
+}
+
+// Close closes the connection, it is reopened on the next request
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return nil
+	}
+	b.open = false
+	return b.client.Close()
+}
+
+// isException returns whether the error is an exception that is returned by the device
+func isException(err error) bool {
+	for _, e := range []error{
+		modbus.ErrIllegalFunction,
+		modbus.ErrIllegalDataAddress,
+		modbus.ErrIllegalDataValue,
+		modbus.ErrServerDeviceFailure,
+		modbus.ErrAcknowledge,
+		modbus.ErrServerDeviceBusy,
+		modbus.ErrMemoryParityError,
+		modbus.ErrGWPathUnavailable,
+		modbus.ErrGWTargetFailedToRespond,
+	} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unit is a device on a bus, it implements the readers of the packages sunspec and registermap and the device of the
+// package powercontrol
+type Unit struct {
+	bus *Bus
+	id  uint8
+}
+
+// ID returns the unit id of the device
+func (u *Unit) ID() uint8 {
+	return u.id
+}
+
+// Bus returns the bus the device is reached through
+func (u *Unit) Bus() *Bus {
+	return u.bus
+}
+
+// ReadRegisters reads the given number of registers of the device, starting at the address
+func (u *Unit) ReadRegisters(address uint16, quantity uint16, regType modbus.RegType) ([]uint16, error) {
+	var result []uint16
+	err := u.bus.do(u.id, func(client *modbus.ModbusClient) error {
+		var err error
+		result, err = client.ReadRegisters(address, quantity, regType)
+		return err
+	})
+	return result, err
+}
+
+// WriteRegister writes a single holding register of the device
+func (u *Unit) WriteRegister(address uint16, value uint16) error {
+	return u.bus.do(u.id, func(client *modbus.ModbusClient) error {
+		return client.WriteRegister(address, value)
+	})
+}
@@ -0,0 +1,166 @@
+package bus
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/simonvetter/modbus"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		url   string
+		conf  modbus.ClientConfiguration
+		units []uint8
+	}{
+		{"tcp://192.168.1.127:1502", modbus.ClientConfiguration{URL: "tcp://192.168.1.127:1502"}, []uint8{1}},
+		{"tcp://gateway:502?unit_id=3", modbus.ClientConfiguration{URL: "tcp://gateway:502"}, []uint8{3}},
+		{
+			"rtu:///dev/ttyUSB0?baud=9600&parity=even&stop_bits=1&data_bits=8&unit_id=1,2, 3",
+			modbus.ClientConfiguration{URL: "rtu:///dev/ttyUSB0", Speed: 9600, DataBits: 8, Parity: modbus.PARITY_EVEN,
+				StopBits: 1},
+			[]uint8{1, 2, 3},
+		},
+		{"rtu:///dev/ttyUSB0?parity=N", modbus.ClientConfiguration{URL: "rtu:///dev/ttyUSB0"}, []uint8{1}},
+	} {
+		conf, units, err := parse(test.url)
+		if err != nil {
+			t.Errorf("%s: %v", test.url, err)
+			continue
+		}
+		if conf != test.conf || fmt.Sprint(units) != fmt.Sprint(test.units) {
+			t.Errorf("%s: got %+v %v, expected %+v %v", test.url, conf, units, test.conf, test.units)
+		}
+	}
+
+	for _, url := range []string{
+		"/dev/ttyUSB0",
+		"rtu:///dev/ttyUSB0?baud=fast",
+		"rtu:///dev/ttyUSB0?parity=x",
+		"rtu:///dev/ttyUSB0?stop_bits=3",
+		"rtu:///dev/ttyUSB0?data_bits=9",
+		"rtu:///dev/ttyUSB0?unit_id=1,256",
+	} {
+		if _, _, err := parse(url); err == nil {
+			t.Errorf("%s: expected an error", url)
+		}
+	}
+}
+
+// handler serves a holding register with the unit id at address 0 of every unit, other addresses are illegal
+type handler struct {
+	mu     sync.Mutex
+	active int
+	max    int
+}
+
+func (h *handler) HandleCoils(req *modbus.CoilsRequest) ([]bool, error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+func (h *handler) HandleDiscreteInputs(req *modbus.DiscreteInputsRequest) ([]bool, error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+func (h *handler) HandleHoldingRegisters(req *modbus.HoldingRegistersRequest) ([]uint16, error) {
+	h.mu.Lock()
+	h.active++
+	if h.active > h.max {
+		h.max = h.active
+	}
+	h.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	h.mu.Lock()
+	h.active--
+	h.mu.Unlock()
+
+	if req.Addr != 0 || req.Quantity != 1 {
+		return nil, modbus.ErrIllegalDataAddress
+	}
+	return []uint16{uint16(req.UnitId)}, nil
+}
+
+func (h *handler) HandleInputRegisters(req *modbus.InputRegistersRequest) ([]uint16, error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+func TestUnits(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	h := &handler{}
+	server, err := modbus.NewServer(&modbus.ServerConfiguration{URL: "tcp://" + addr, MaxClients: 2}, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	metrics := smr.NewMetrics("test")
+	b, units, err := Open("tcp://"+addr+"?unit_id=1,2", time.Second, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	// The same bus is returned for the same host
+	if other, _, err := Open("tcp://"+addr+"?unit_id=3", time.Second, metrics); err != nil || other != b {
+		t.Errorf("expected the same bus, got %v", err)
+	}
+	if _, _, err := Open("tcp://"+addr, 2*time.Second, metrics); err == nil {
+		t.Errorf("expected an error for other options")
+	}
+
+	// The units are read concurrently, but the requests are serialized
+	var wg sync.WaitGroup
+	for _, id := range units {
+		u := b.Unit(id)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				v, err := u.ReadRegisters(0, 1, modbus.HOLDING_REGISTER)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if v[0] != uint16(u.ID()) {
+					t.Errorf("unit %d read %d", u.ID(), v[0])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if h.max != 1 {
+		t.Errorf("expected serialized requests, got %d concurrent requests", h.max)
+	}
+
+	_, err = b.Unit(1).ReadRegisters(10, 1, modbus.HOLDING_REGISTER)
+	if !errors.Is(err, modbus.ErrIllegalDataAddress) {
+		t.Errorf("expected an illegal data address, got %v", err)
+	}
+
+	for name, expected := range map[string]int64{
+		"modbus_requests":   21,
+		"modbus_errors":     1,
+		"modbus_exceptions": 1,
+		"modbus_timeouts":   0,
+	} {
+		if v := metrics.Get(name); v != expected {
+			t.Errorf("%s is %d, expected %d", name, v, expected)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package meterstanden
+
+import (
+	"io"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// CapacityTariff holds the registers that Belgian (e-MUCS) meters send for the capacity tariff. The capacity tariff is
+// based on the monthly peak of the average demand over a quarter of an hour.
+type CapacityTariff struct {
+	Timestamp              time.Time    `json:"time,omitempty"`
+	Version                int64        `json:"version,omitempty"`
+	CurrentAverageDemand   int64        `json:"currentAverageDemand,omitempty"` // W
+	MaximumDemand          int64        `json:"maximumDemand,omitempty"`        // W
+	MaximumDemandTimestamp time.Time    `json:"maximumDemandTime,omitempty"`
+	MaximumDemandHistory   []DemandPeak `json:"maximumDemandHistory,omitempty"`
+}
+
+// DemandPeak is the peak of the average demand in a month (0-0:98.1.0). The period ends at the start of the next month.
+type DemandPeak struct {
+	PeriodEnd time.Time `json:"periodEnd"`
+	Timestamp time.Time `json:"time"`
+	Value     int64     `json:"value"` // W
+}
+
+type CapacityTariffHandler struct {
+	IMeasurementHandler[CapacityTariff]
+}
+
+func (h CapacityTariffHandler) Name() string {
+	return "capacity"
+}
+
+func (h CapacityTariffHandler) CreatePoint(m CapacityTariff) *write.Point {
+	return influxdb2.NewPoint(
+		"capacity",
+		map[string]string{
+			"source": "p1-meter",
+		},
+		map[string]interface{}{
+			"currentAverageDemand":   float64(m.CurrentAverageDemand),
+			"maximumDemand":          float64(m.MaximumDemand),
+			"maximumDemandTimestamp": m.MaximumDemandTimestamp.Unix(),
+		},
+		m.Timestamp,
+	)
+}
+
+func (h CapacityTariffHandler) GetTimestamp(m CapacityTariff) time.Time {
+	return m.Timestamp
+}
+
+func (h CapacityTariffHandler) WriteMeasurement(writer io.Writer, m CapacityTariff, previous CapacityTariff) error {
+	if err := WriteValue(writer, m.Timestamp.Unix(), previous.Timestamp.Unix()); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, m.Version, previous.Version); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, m.CurrentAverageDemand, previous.CurrentAverageDemand); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, m.MaximumDemand, previous.MaximumDemand); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, m.MaximumDemandTimestamp.Unix(), previous.MaximumDemandTimestamp.Unix()); err != nil {
+		return err
+	}
+	return writeDemandPeaks(writer, m.MaximumDemandHistory, previous.MaximumDemandHistory)
+}
+
+// writeDemandPeaks writes the number of peaks followed by the end of the period, timestamp and value of every peak. The values are
+// written relative to the peak at the same position in the previous history.
+func writeDemandPeaks(writer io.Writer, peaks []DemandPeak, previousPeaks []DemandPeak) error {
+	if err := WriteValue(writer, int64(len(peaks)), int64(len(previousPeaks))); err != nil {
+		return err
+	}
+	for i, peak := range peaks {
+		previous := DemandPeak{PeriodEnd: time.Unix(0, 0), Timestamp: time.Unix(0, 0)}
+		if i < len(previousPeaks) {
+			previous = previousPeaks[i]
+		}
+		if err := WriteValue(writer, peak.PeriodEnd.Unix(), previous.PeriodEnd.Unix()); err != nil {
+			return err
+		}
+		if err := WriteValue(writer, peak.Timestamp.Unix(), previous.Timestamp.Unix()); err != nil {
+			return err
+		}
+		if err := WriteValue(writer, peak.Value, previous.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h CapacityTariffHandler) ZeroMeasurement() CapacityTariff {
+	return zeroCapacityTariff
+}
+
+var zeroCapacityTariff = createZeroCapacityTariff()
+
+func createZeroCapacityTariff() CapacityTariff {
+	capacityTariff := CapacityTariff{}
+	capacityTariff.Timestamp = time.Unix(0, 0)
+	capacityTariff.MaximumDemandTimestamp = time.Unix(0, 0)
+	return capacityTariff
+}
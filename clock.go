@@ -0,0 +1,94 @@
+package meterstanden
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ClockPolicy determines which clock is used for the timestamps of the measurements
+type ClockPolicy int
+
+const (
+	// ClockMeter uses the clock of the device, or the clock of the host when the device does not send a timestamp
+	ClockMeter ClockPolicy = iota
+	// ClockHost uses the clock of the host at which the measurement was received
+	ClockHost
+	// ClockHostWithDriftWarning uses the clock of the host and warns when the clock of the device drifts
+	ClockHostWithDriftWarning
+)
+
+// DefaultMaxClockDrift is the drift between the clock of the device and the host after which a warning is logged
+const DefaultMaxClockDrift = 1 * time.Minute
+
+var clockPolicyNames = map[ClockPolicy]string{
+	ClockMeter:                "meter",
+	ClockHost:                 "host",
+	ClockHostWithDriftWarning: "host-with-drift-warning",
+}
+
+// ParseClockPolicy parses a policy like `meter`, `host` or `host-with-drift-warning`
+func ParseClockPolicy(s string) (ClockPolicy, error) {
+	for policy, name := range clockPolicyNames {
+		if name == s {
+			return policy, nil
+		}
+	}
+	return ClockMeter, fmt.Errorf("unknown clock policy '%s'", s)
+}
+
+func (p ClockPolicy) String() string {
+	return clockPolicyNames[p]
+}
+
+// Clock determines the timestamps of the measurements according to its policy. The drift between the clocks of the
+// device and the host is reported in the metrics as `clock_drift` (in seconds). A nil Clock uses the meter policy.
+type Clock struct {
+	Policy ClockPolicy
+	// The drift after which a warning is logged, DefaultMaxClockDrift when zero
+	MaxDrift time.Duration
+	Metrics  *Metrics
+
+	drifting bool
+}
+
+// Timestamp returns the timestamp of a measurement, given the time of the device (zero when the device has no clock)
+// and the time at which the host received the measurement.
+func (c *Clock) Timestamp(device time.Time, host time.Time) time.Time {
+	if c == nil {
+		if device.IsZero() {
+			return host
+		}
+		return device
+	}
+
+	if !device.IsZero() {
+		drift := device.Sub(host)
+		c.Metrics.Set("clock_drift", int64(drift.Seconds()))
+		if c.Policy == ClockHostWithDriftWarning {
+			c.checkDrift(drift)
+		}
+	}
+
+	if c.Policy == ClockMeter && !device.IsZero() {
+		return device
+	}
+	return host
+}
+
+// checkDrift logs a warning when the drift exceeds the maximum, and when it is back within the maximum
+func (c *Clock) checkDrift(drift time.Duration) {
+	maxDrift := c.MaxDrift
+	if maxDrift == 0 {
+		maxDrift = DefaultMaxClockDrift
+	}
+
+	drifting := drift > maxDrift || drift < -maxDrift
+	if drifting && !c.drifting {
+		log.Warnf("The clock of the device drifted %s from the clock of the host", drift.Round(time.Second))
+	} else if !drifting && c.drifting {
+		log.Infof("The clock of the device is back within %s of the clock of the host", maxDrift)
+	}
+	c.drifting = drifting
+}
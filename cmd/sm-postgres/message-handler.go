@@ -2,39 +2,74 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
-	"os"
+	"time"
 
 	"github.com/eclipse/paho.golang/paho"
+	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/jackc/pgx/v4"
+	log "github.com/sirupsen/logrus"
 )
 
 // handler is a simple struct that provides a function to be called when a message is received. The message is parsed
-// and the count followed by the raw message is written to the file (this makes it easier to sort the file)
+// as a telegram and the measurements are stored in the database.
 type handler struct {
-}
+	conn *pgx.Conn
+
+	// The timestamps of the last stored gas readings per M-Bus channel
+	lastGasTimestamps map[int8]time.Time
 
-// NewHandler creates a new output handler and opens the output file (if applicable)
-func NewHandler() *handler {
-	return &handler{}
+	// The timestamps of the stored monthly peaks of the capacity tariff
+	storedPeakTimestamps map[time.Time]bool
 }
 
-// Close closes the file
-func (o *handler) Close() {
+// NewHandler creates a new handler that stores the telegrams using the given connection
+func NewHandler(conn *pgx.Conn) *handler {
+	return &handler{
+		conn:                 conn,
+		lastGasTimestamps:    map[int8]time.Time{},
+		storedPeakTimestamps: map[time.Time]bool{},
+	}
 }
 
 // handle is called when a message is received
-func (o *handler) handle(msg *paho.Publish) {
-	if err := json.Unmarshal(msg.Payload, &m); err != nil {
-		fmt.Printf("Message could not be parsed (%s): %s", msg.Payload, err)
+func (h *handler) handle(msg *paho.Publish) {
+	log.Info("Receiving smart meter telegram")
+	telegram := smr.Telegram{}
+	if err := json.Unmarshal(msg.Payload, &telegram); err != nil {
+		log.Errorf("Unable to unmarshal the telegram JSON: %v", err)
+		return
 	}
-	if o.f != nil {
-		// Write out the number (make it long enough that sorting works) and the payload
-		if _, err := o.f.WriteString(fmt.Sprintf("%09d %s\n", m.Count, msg.Payload)); err != nil {
-			fmt.Printf("ERROR writing to file: %s", err)
+
+	saveTelegram(h.conn, &telegram)
+
+	// The gas meter only updates its reading every couple of minutes, so only store the readings that changed
+	for _, reading := range telegram.MBus {
+		if reading.DeviceType != smr.MBusDeviceTypeGas || reading.Timestamp.Equal(h.lastGasTimestamps[reading.Channel]) {
+			continue
 		}
+		h.lastGasTimestamps[reading.Channel] = reading.Timestamp
+		saveGasReading(h.conn, &reading)
+	}
+
+	if telegram.CapacityTariff != nil {
+		h.handleCapacityTariff(telegram.Timestamp, telegram.CapacityTariff)
 	}
+}
+
+// handleCapacityTariff stores the current average demand and the monthly peaks that were not stored before
+func (h *handler) handleCapacityTariff(timestamp time.Time, capacityTariff *smr.CapacityTariff) {
+	saveAverageDemand(h.conn, timestamp, capacityTariff.CurrentAverageDemand)
 
-	if o.writeToStdOut {
-		fmt.Printf("received message: %s\n", msg.Payload)
+	peaks := append([]smr.DemandPeak{{
+		Timestamp: capacityTariff.MaximumDemandTimestamp,
+		Value:     capacityTariff.MaximumDemand,
+	}}, capacityTariff.MaximumDemandHistory...)
+
+	for _, peak := range peaks {
+		if peak.Timestamp.IsZero() || h.storedPeakTimestamps[peak.Timestamp] {
+			continue
+		}
+		h.storedPeakTimestamps[peak.Timestamp] = true
+		saveDemandPeak(h.conn, &peak)
 	}
-}
\ No newline at end of file
+}
@@ -3,18 +3,29 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/hex"
 	"os"
-	"strconv"
+	"sync"
+	"time"
+	_ "time/tzdata"
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 
 	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/gmulders/smart-meter-readings/dsmr"
+	"github.com/gmulders/smart-meter-readings/source"
 	log "github.com/sirupsen/logrus"
-	"github.com/tarm/serial"
 )
 
 const (
 	serialPortEnvName      = "SERIAL_PORT"
+	dsmrVersionEnvName     = "DSMR_VERSION"
+	captureFileEnvName     = "P1_CAPTURE_FILE"
+	timezoneEnvName        = "METER_TIMEZONE"
+	clockPolicyEnvName     = "CLOCK_POLICY"
+	clockMaxDriftEnvName   = "CLOCK_MAX_DRIFT"
+	decryptionKeyEnvName   = "P1_DECRYPTION_KEY"
+	authKeyEnvName         = "P1_AUTHENTICATION_KEY"
 	influxServerUrlEnvName = "INLFUX_SERVER_URL"
 	influxAuthTokenEnvName = "INLFUX_AUTH_TOKEN"
 )
@@ -22,7 +33,12 @@ const (
 func main() {
 
 	channel := make(chan smr.Telegram)
+	telegramChannel := make(chan smr.Telegram)
 	handler := smr.TelegramHandler{}
+	mbusChannel := make(chan smr.MBusReading)
+	mbusHandler := smr.MBusReadingHandler{}
+	capacityChannel := make(chan smr.CapacityTariff)
+	capacityHandler := smr.CapacityTariffHandler{}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -32,9 +48,54 @@ func main() {
 		log.Fatalf("Empty environment property %s '%s'", serialPortEnvName, serialPort)
 	}
 
-	config := &serial.Config{
-		Name: serialPort,
-		Baud: 115200,
+	dsmrVersion := dsmr.VersionAuto
+	if dsmrVersionString := os.Getenv(dsmrVersionEnvName); dsmrVersionString != "" {
+		var err error
+		dsmrVersion, err = dsmr.ParseVersion(dsmrVersionString)
+		if err != nil {
+			log.Fatalf("Could not parse %s '%s'", dsmrVersionEnvName, dsmrVersionString)
+		}
+	}
+
+	serialOptions, err := source.SerialOptionsFromEnv(*dsmrVersion.SerialConfig(serialPort))
+	if err != nil {
+		log.Fatal(err)
+	}
+	serialOptions.Probe = dsmr.ContainsTelegram
+
+	// The telegrams of meters that encrypt them are only found after decryption, also when the port is probed
+	key, authKey := decryptionKeys()
+	if key != nil {
+		serialOptions.Probe, err = dsmr.ContainsEncryptedTelegram(key, authKey)
+		if err != nil {
+			log.Fatalf("Could not use %s: %v", decryptionKeyEnvName, err)
+		}
+	}
+
+	// The time zone of the meter, e.g. Europe/Amsterdam or Europe/Brussels
+	var location *time.Location
+	if timezone := os.Getenv(timezoneEnvName); timezone != "" {
+		var err error
+		location, err = time.LoadLocation(timezone)
+		if err != nil {
+			log.Fatalf("Could not load %s '%s': %v", timezoneEnvName, timezone, err)
+		}
+	}
+
+	clock := &smr.Clock{}
+	if clockPolicy := os.Getenv(clockPolicyEnvName); clockPolicy != "" {
+		var err error
+		clock.Policy, err = smr.ParseClockPolicy(clockPolicy)
+		if err != nil {
+			log.Fatalf("Could not parse %s: %v", clockPolicyEnvName, err)
+		}
+	}
+	if maxDrift := os.Getenv(clockMaxDriftEnvName); maxDrift != "" {
+		var err error
+		clock.MaxDrift, err = time.ParseDuration(maxDrift)
+		if err != nil {
+			log.Fatalf("Could not parse %s '%s'", clockMaxDriftEnvName, maxDrift)
+		}
 	}
 
 	influxServerUrl := os.Getenv(influxServerUrlEnvName)
@@ -53,71 +114,116 @@ func main() {
 		influxdb2.DefaultOptions(),
 	)
 
-	serial, err := serial.OpenPort(config)
+	metrics := smr.NewMetrics("sm-reader")
+	clock.Metrics = metrics
+	go metrics.WriteMetricsStream(ctx, client, time.Minute)
+
+	// The serial port is either a serial device or the url of a P1-to-Ethernet bridge (tcp://host:port) or a
+	// capture to replay (replay://file), the device may be a glob pattern like /dev/serial/by-id/usb-FTDI_*
+	p1, err := source.Open(serialPort, serialOptions, metrics)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	reader := bufio.NewReader(serial)
-
-	go smr.WriteMeasurementStream[smr.Telegram](ctx, channel, handler, client)
-
-	readTelegramStream(reader, channel)
-}
-
-func readTelegramStream(reader *bufio.Reader, ch chan smr.Telegram) {
-	var crc uint16 = 0
-	var telegram = &smr.Telegram{}
-
-	for {
-		// Read until next line feed (\n), this character is included in the resulting array
-		bytes, err := reader.ReadBytes(0x0a)
-
+	// Record the raw bytes, e.g. to reproduce a parser bug by replaying them (replay://file?speed=max)
+	if captureFile := os.Getenv(captureFileEnvName); captureFile != "" {
+		capture, err := source.NewCaptureReader(p1, captureFile)
 		if err != nil {
-			log.Error(err)
-			continue
+			log.Fatal(err)
 		}
+		defer capture.Close()
+		p1 = capture
+	}
 
-		if len(bytes) == 0 {
-			continue
+	if key != nil {
+		p1, err = dsmr.NewDecryptingReader(p1, key, authKey)
+		if err != nil {
+			log.Fatal(err)
 		}
+	}
 
-		// Instead of resetting the crc and telegram object here (if the first character is 0x2f), we do this at the
-		// start of this function and after receiving the checksum of the telegram (whether it is valid or not).
-		// if bytes[0] == 0x2f {
-		//	crc = 0
-		//	telegram = &smr.Telegram{}
-		// }
-
-		if bytes[0] != 0x21 {
-			crc = crc16(crc, bytes)
-			parseLine(telegram, string(bytes))
-			continue
-		}
+	reader := bufio.NewReader(p1)
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		smr.WriteMeasurementStream[smr.Telegram](ctx, telegramChannel, handler, client)
+	}()
+	go func() {
+		defer wg.Done()
+		smr.WriteMeasurementStream[smr.MBusReading](ctx, mbusChannel, mbusHandler, client)
+	}()
+	go func() {
+		defer wg.Done()
+		smr.WriteMeasurementStream[smr.CapacityTariff](ctx, capacityChannel, capacityHandler, client)
+	}()
+	go splitTelegrams(channel, telegramChannel, mbusChannel, capacityChannel)
+
+	if err := dsmr.ReadTelegramStream(reader, channel, dsmr.Config{
+		Version:  dsmrVersion,
+		Location: location,
+		Clock:    clock,
+		Metrics:  metrics,
+	}); err != nil {
+		log.Fatal(err)
+	}
 
-		// When we get here, the line received contains a checksum and the telegram is finished
+	// The end of a replayed capture is reached, wait until the last telegram is written before the remaining points
+	// are flushed
+	log.Info("End of stream")
+	close(channel)
+	wg.Wait()
+	client.Close()
+}
 
-		crc = crc16(crc, []byte{0x21})
-		expectedCRC, err := strconv.ParseUint(string(bytes[1:5]), 16, 16)
-		if err != nil {
-			log.Error(err)
-			continue
+// splitTelegrams forwards the telegrams and sends the readings of the M-Bus devices and the capacity tariff registers to
+// separate channels. The M-Bus devices only update their reading every couple of minutes, so a reading is only sent
+// when its timestamp changed. The channels are closed when ch is closed.
+func splitTelegrams(ch chan smr.Telegram, telegramCh chan smr.Telegram, mbusCh chan smr.MBusReading,
+	capacityCh chan smr.CapacityTariff) {
+	defer close(telegramCh)
+	defer close(mbusCh)
+	defer close(capacityCh)
+	lastTimestamps := map[int8]time.Time{}
+
+	for telegram := range ch {
+		telegramCh <- telegram
+
+		for _, reading := range telegram.MBus {
+			if reading.Timestamp.IsZero() || reading.Timestamp.Equal(lastTimestamps[reading.Channel]) {
+				continue
+			}
+			lastTimestamps[reading.Channel] = reading.Timestamp
+			mbusCh <- reading
 		}
 
-		if uint16(expectedCRC) != crc {
-			log.Error("CRC mismatch")
-
-			// Reset the crc and telegram object and continue, better luck next telegram
-			crc = 0
-			telegram = &smr.Telegram{}
-			continue
+		if telegram.CapacityTariff != nil {
+			capacityTariff := *telegram.CapacityTariff
+			capacityTariff.Timestamp = telegram.Timestamp
+			capacityCh <- capacityTariff
 		}
+	}
+}
 
-		// The telegram is valid; send it to the channel
-		ch <- *telegram
+// decryptionKeys returns the keys of meters that send encrypted DLMS frames, like the Luxembourg Smarty meters. The keys
+// are hex encoded, when no authentication key is given the Smarty key is used. The key is nil when it is not set.
+func decryptionKeys() ([]byte, []byte) {
+	keyString := os.Getenv(decryptionKeyEnvName)
+	if keyString == "" {
+		return nil, nil
+	}
+	key, err := hex.DecodeString(keyString)
+	if err != nil {
+		log.Fatalf("Could not parse %s: %v", decryptionKeyEnvName, err)
+	}
 
-		// Reset the crc and telegram object
-		crc = 0
-		telegram = &smr.Telegram{}
+	authKey := dsmr.SmartyAuthenticationKey
+	if authKeyString := os.Getenv(authKeyEnvName); authKeyString != "" {
+		authKey, err = hex.DecodeString(authKeyString)
+		if err != nil {
+			log.Fatalf("Could not parse %s: %v", authKeyEnvName, err)
+		}
 	}
+	return key, authKey
 }
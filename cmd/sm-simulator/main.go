@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	outputEnvName    = "SIMULATOR_OUTPUT"
+	intervalEnvName  = "SIMULATOR_INTERVAL"
+	faultRateEnvName = "SIMULATOR_FAULT_RATE"
+	faultsEnvName    = "SIMULATOR_FAULTS"
+)
+
+// The faults that can be injected in the telegrams
+const (
+	faultCRC      = "crc"
+	faultTruncate = "truncate"
+	faultClock    = "clock"
+)
+
+// The simulator generates DSMR 5 telegrams, e.g. to test the reader without a meter attached. The telegrams are
+// written to the output, which is either `stdout`, `pty` (a pseudo terminal that can be used as the serial port of the
+// reader) or `tcp://host:port` (a listener that sends the telegrams to every client, like a P1-to-Ethernet bridge).
+func main() {
+
+	output := os.Getenv(outputEnvName)
+	if output == "" {
+		output = "stdout"
+	}
+
+	interval := 1 * time.Second
+	if intervalString := os.Getenv(intervalEnvName); intervalString != "" {
+		var err error
+		interval, err = time.ParseDuration(intervalString)
+		if err != nil {
+			log.Fatalf("Could not parse %s '%s'", intervalEnvName, intervalString)
+		}
+	}
+
+	// The chance that a fault is injected in a telegram
+	faultRate := 0.0
+	if faultRateString := os.Getenv(faultRateEnvName); faultRateString != "" {
+		var err error
+		faultRate, err = strconv.ParseFloat(faultRateString, 64)
+		if err != nil {
+			log.Fatalf("Could not parse %s '%s'", faultRateEnvName, faultRateString)
+		}
+	}
+
+	faults := []string{faultCRC, faultTruncate, faultClock}
+	if faultsString := os.Getenv(faultsEnvName); faultsString != "" {
+		faults = strings.Split(faultsString, ",")
+		for _, fault := range faults {
+			if fault != faultCRC && fault != faultTruncate && fault != faultClock {
+				log.Fatalf("Unknown fault '%s' in %s", fault, faultsEnvName)
+			}
+		}
+	}
+
+	location, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		log.Warnf("Could not load the Dutch time zone, using the local time zone: %v", err)
+		location = time.Local
+	}
+
+	writer, err := openOutput(output)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	simulate(writer, newMeter(random, location, time.Now()), random, interval, faultRate, faults)
+}
+
+// simulate writes a telegram to the writer every interval
+func simulate(writer io.Writer, m *meter, random *rand.Rand, interval time.Duration, faultRate float64, faults []string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var clockOffset time.Duration
+
+	for now := range ticker.C {
+		fault := ""
+		if random.Float64() < faultRate {
+			fault = faults[random.Intn(len(faults))]
+		}
+
+		if fault == faultClock {
+			// The clock of the meter jumps, the next clock fault syncs it again
+			if clockOffset == 0 {
+				clockOffset = time.Duration(random.Intn(240)-120) * time.Minute
+			} else {
+				clockOffset = 0
+			}
+			log.Infof("Clock offset %s", clockOffset)
+		}
+
+		telegram := m.next(now, clockOffset)
+
+		switch fault {
+		case faultCRC:
+			// Corrupt a byte of the telegram, so that the CRC does not match
+			b := []byte(telegram)
+			b[20+random.Intn(len(b)-30)] ^= 0x01
+			telegram = string(b)
+			log.Info("Corrupted the telegram")
+		case faultTruncate:
+			telegram = telegram[:random.Intn(len(telegram)-8)]
+			log.Info("Truncated the telegram")
+		}
+
+		if _, err := io.WriteString(writer, telegram); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/gmulders/smart-meter-readings/dsmr"
+)
+
+// gasInterval is the interval at which the gas meter sends its reading to the electricity meter
+const gasInterval = 5 * time.Minute
+
+// meter simulates a three phase DSMR 5 meter with solar panels on the first phase and a gas meter on M-Bus channel 1.
+// The counters are in Wh and dm3.
+type meter struct {
+	random   *rand.Rand
+	location *time.Location
+
+	equipmentIdentifier    string
+	gasEquipmentIdentifier string
+
+	consumedTariff1  float64
+	consumedTariff2  float64
+	deliveredTariff1 float64
+	deliveredTariff2 float64
+	gas              float64
+	gasTimestamp     time.Time
+	powerFailures    int64
+
+	// The last time the counters were updated
+	last time.Time
+	// Until when an appliance (e.g. a kettle or a washing machine) is switched on, and its power on each phase in W
+	applianceUntil time.Time
+	appliance      [3]float64
+	// The fraction of the solar power that is not blocked by clouds
+	clouds float64
+}
+
+func newMeter(random *rand.Rand, location *time.Location, now time.Time) *meter {
+	return &meter{
+		random:                 random,
+		location:               location,
+		equipmentIdentifier:    "E0043007052870318",
+		gasEquipmentIdentifier: "G0035503426751718",
+		consumedTariff1:        4_527_341,
+		consumedTariff2:        3_916_012,
+		deliveredTariff1:       1_204_873,
+		deliveredTariff2:       2_733_449,
+		gas:                    3_894_117,
+		gasTimestamp:           now.Truncate(gasInterval),
+		powerFailures:          4,
+		last:                   now,
+		clouds:                 1,
+	}
+}
+
+// tariff returns the tariff at the given time: the low tariff (1) at night and in the weekend, otherwise the normal
+// tariff (2)
+func (m *meter) tariff(t time.Time) int {
+	t = t.In(m.location)
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday || t.Hour() < 7 || t.Hour() >= 23 {
+		return 1
+	}
+	return 2
+}
+
+// load returns the power in W used on each phase at the given time
+func (m *meter) load(t time.Time) [3]float64 {
+	local := t.In(m.location)
+	hour := float64(local.Hour()) + float64(local.Minute())/60
+
+	// A base load plus a morning and an evening peak
+	peak := 400*math.Exp(-math.Pow(hour-7.5, 2)) + 900*math.Exp(-math.Pow(hour-19, 2)/4)
+	load := [3]float64{90 + peak*0.6, 45 + peak*0.25, 35 + peak*0.15}
+
+	if t.After(m.applianceUntil) && m.random.Float64() < 0.002 {
+		m.applianceUntil = t.Add(time.Duration(1+m.random.Intn(30)) * time.Minute)
+		m.appliance = [3]float64{}
+		m.appliance[m.random.Intn(3)] = 1000 + 1200*m.random.Float64()
+	}
+	for i := range load {
+		if t.Before(m.applianceUntil) {
+			load[i] += m.appliance[i]
+		}
+		load[i] *= 0.95 + 0.1*m.random.Float64()
+	}
+	return load
+}
+
+// solar returns the power in W produced by the solar panels at the given time
+func (m *meter) solar(t time.Time) float64 {
+	local := t.In(m.location)
+	hour := float64(local.Hour()) + float64(local.Minute())/60
+	if hour < 6 || hour > 21 {
+		return 0
+	}
+
+	// Clouds drift by slowly
+	m.clouds = math.Min(1, math.Max(0.2, m.clouds+0.02*(m.random.Float64()-0.5)))
+	return 3200 * math.Sin(math.Pi*(hour-6)/15) * m.clouds
+}
+
+// next advances the meter to the given time and returns the telegram, with the given clock offset applied to its
+// timestamps
+func (m *meter) next(now time.Time, clockOffset time.Duration) string {
+	load := m.load(now)
+	solar := m.solar(now)
+
+	// The solar panels are connected to the first phase
+	var consumption, delivery [3]float64
+	for i := range load {
+		consumption[i] = load[i]
+	}
+	consumption[0] -= solar
+	if consumption[0] < 0 {
+		delivery[0] = -consumption[0]
+		consumption[0] = 0
+	}
+
+	totalConsumption := consumption[0] + consumption[1] + consumption[2]
+	totalDelivery := delivery[0]
+	net := totalConsumption - totalDelivery
+
+	// The meter only counts the net energy
+	hours := now.Sub(m.last).Hours()
+	tariff := m.tariff(now)
+	switch {
+	case net > 0 && tariff == 1:
+		m.consumedTariff1 += net * hours
+	case net > 0:
+		m.consumedTariff2 += net * hours
+	case tariff == 1:
+		m.deliveredTariff1 -= net * hours
+	default:
+		m.deliveredTariff2 -= net * hours
+	}
+	m.last = now
+
+	if gasTimestamp := now.Truncate(gasInterval); gasTimestamp.After(m.gasTimestamp) {
+		hour := now.In(m.location).Hour()
+		heating := 40.0
+		if hour >= 6 && hour < 23 {
+			heating = 120
+		}
+		m.gas += gasTimestamp.Sub(m.gasTimestamp).Hours() * heating * (0.5 + m.random.Float64())
+		m.gasTimestamp = gasTimestamp
+	}
+
+	var b strings.Builder
+	b.WriteString("/ISk5\\2MT382-1000\r\n\r\n")
+	line := func(format string, a ...any) {
+		fmt.Fprintf(&b, format+"\r\n", a...)
+	}
+	line("1-3:0.2.8(50)")
+	line("0-0:1.0.0(%s)", m.timestamp(now.Add(clockOffset)))
+	line("0-0:96.1.1(%s)", hex.EncodeToString([]byte(m.equipmentIdentifier)))
+	line("1-0:1.8.1(%s*kWh)", kilo(m.consumedTariff1, 10))
+	line("1-0:1.8.2(%s*kWh)", kilo(m.consumedTariff2, 10))
+	line("1-0:2.8.1(%s*kWh)", kilo(m.deliveredTariff1, 10))
+	line("1-0:2.8.2(%s*kWh)", kilo(m.deliveredTariff2, 10))
+	line("0-0:96.14.0(%04d)", tariff)
+	line("1-0:1.7.0(%s*kW)", kilo(totalConsumption, 6))
+	line("1-0:2.7.0(%s*kW)", kilo(totalDelivery, 6))
+	line("0-0:96.7.21(%05d)", m.powerFailures)
+	line("0-0:96.7.9(00002)")
+	line("1-0:99.97.0(1)(0-0:96.7.19)(%s)(0000002913*s)", m.timestamp(time.Date(2021, 3, 14, 9, 26, 52, 0, m.location)))
+	line("1-0:32.32.0(00002)")
+	line("1-0:52.32.0(00001)")
+	line("1-0:72.32.0(00001)")
+	line("1-0:32.36.0(00000)")
+	line("1-0:52.36.0(00000)")
+	line("1-0:72.36.0(00000)")
+	line("0-0:96.13.0()")
+
+	var voltage [3]float64
+	for i := range voltage {
+		// The voltage drops with the load and rises with the delivery
+		voltage[i] = 231 + 2*m.random.Float64() - consumption[i]/1000 + delivery[i]/800
+	}
+	for i, code := range []int{32, 52, 72} {
+		line("1-0:%d.7.0(%05.1f*V)", code, voltage[i])
+	}
+	for i, code := range []int{31, 51, 71} {
+		line("1-0:%d.7.0(%03d*A)", code, int(math.Round((consumption[i]+delivery[i])/voltage[i])))
+	}
+	for i, code := range []int{21, 41, 61} {
+		line("1-0:%d.7.0(%s*kW)", code, kilo(consumption[i], 6))
+	}
+	for i, code := range []int{22, 42, 62} {
+		line("1-0:%d.7.0(%s*kW)", code, kilo(delivery[i], 6))
+	}
+	line("0-1:24.1.0(003)")
+	line("0-1:96.1.0(%s)", hex.EncodeToString([]byte(m.gasEquipmentIdentifier)))
+	line("0-1:24.2.1(%s)(%s*m3)", m.timestamp(m.gasTimestamp.Add(clockOffset)), kilo(m.gas, 9))
+	b.WriteString("!")
+
+	return withCRC(b.String())
+}
+
+// timestamp formats the time like YYMMDDhhmmssX, where X is S in summer time and W in winter time
+func (m *meter) timestamp(t time.Time) string {
+	t = t.In(m.location)
+	if t.IsDST() {
+		return t.Format("060102150405") + "S"
+	}
+	return t.Format("060102150405") + "W"
+}
+
+// kilo formats the value divided by 1000 with three decimals, padded with zeros to the given width
+func kilo(value float64, width int) string {
+	return fmt.Sprintf("%0*.3f", width, value/1000)
+}
+
+// withCRC appends the CRC to a telegram that ends with the `!`
+func withCRC(telegram string) string {
+	return fmt.Sprintf("%s%04X\r\n", telegram, dsmr.CRC16(0, []byte(telegram)))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// openOutput opens the output with the given name: `stdout`, `pty` or `tcp://host:port`
+func openOutput(name string) (io.Writer, error) {
+	switch name {
+	case "stdout":
+		return os.Stdout, nil
+	case "pty":
+		return openPty()
+	}
+
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "tcp" {
+		return listen(u.Host)
+	}
+	return nil, fmt.Errorf("unsupported output '%s'", name)
+}
+
+// broadcaster writes to all connected clients, clients that fail are disconnected
+type broadcaster struct {
+	mu      sync.Mutex
+	clients map[net.Conn]bool
+}
+
+// listen accepts clients on the address and returns a writer that writes to all of them
+func listen(address string) (*broadcaster, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Listening on %s", listener.Addr())
+
+	b := &broadcaster{clients: map[net.Conn]bool{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			log.Infof("Client %s connected", conn.RemoteAddr())
+			b.mu.Lock()
+			b.clients[conn] = true
+			b.mu.Unlock()
+		}
+	}()
+	return b, nil
+}
+
+func (b *broadcaster) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for conn := range b.clients {
+		if _, err := conn.Write(p); err != nil {
+			log.Infof("Client %s disconnected: %v", conn.RemoteAddr(), err)
+			conn.Close()
+			delete(b.clients, conn)
+		}
+	}
+	return len(p), nil
+}
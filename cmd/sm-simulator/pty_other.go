@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// openPty is only supported on Linux
+func openPty() (*os.File, error) {
+	return nil, errors.New("pty output is only supported on linux")
+}
@@ -5,15 +5,22 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strconv"
+	"time"
+	_ "time/tzdata"
 
 	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/gmulders/smart-meter-readings/dsmr"
+	"github.com/gmulders/smart-meter-readings/source"
 	log "github.com/sirupsen/logrus"
-	"github.com/tarm/serial"
 )
 
 const (
 	serialPortEnvName      = "SERIAL_PORT"
+	dsmrVersionEnvName     = "DSMR_VERSION"
+	captureFileEnvName     = "P1_CAPTURE_FILE"
+	timezoneEnvName        = "METER_TIMEZONE"
+	clockPolicyEnvName     = "CLOCK_POLICY"
+	clockMaxDriftEnvName   = "CLOCK_MAX_DRIFT"
 	influxServerUrlEnvName = "INLFUX_SERVER_URL"
 	influxAuthTokenEnvName = "INLFUX_AUTH_TOKEN"
 )
@@ -30,77 +37,70 @@ func main() {
 		log.Fatalf("Empty environment property %s '%s'", serialPortEnvName, serialPort)
 	}
 
-	config := &serial.Config{
-		Name: serialPort,
-		Baud: 115200,
+	dsmrVersion := dsmr.VersionAuto
+	if dsmrVersionString := os.Getenv(dsmrVersionEnvName); dsmrVersionString != "" {
+		var err error
+		dsmrVersion, err = dsmr.ParseVersion(dsmrVersionString)
+		if err != nil {
+			log.Fatalf("Could not parse %s '%s'", dsmrVersionEnvName, dsmrVersionString)
+		}
 	}
 
-	serial, err := serial.OpenPort(config)
+	serialOptions, err := source.SerialOptionsFromEnv(*dsmrVersion.SerialConfig(serialPort))
 	if err != nil {
 		log.Fatal(err)
 	}
+	serialOptions.Probe = dsmr.ContainsTelegram
 
-	reader := bufio.NewReader(serial)
-
-	go output(channel)
-
-	readTelegramStream(reader, channel)
-}
-
-func readTelegramStream(reader *bufio.Reader, ch chan smr.Telegram) {
-	var crc uint16 = 0
-	var telegram = &smr.Telegram{}
-
-	for {
-		// Read until next line feed (\n), this character is included in the resulting array
-		bytes, err := reader.ReadBytes(0x0a)
-
+	// The time zone of the meter, e.g. Europe/Amsterdam or Europe/Brussels
+	var location *time.Location
+	if timezone := os.Getenv(timezoneEnvName); timezone != "" {
+		var err error
+		location, err = time.LoadLocation(timezone)
 		if err != nil {
-			log.Error(err)
-			continue
+			log.Fatalf("Could not load %s '%s': %v", timezoneEnvName, timezone, err)
 		}
+	}
 
-		if len(bytes) == 0 {
-			continue
+	clock := &smr.Clock{}
+	if clockPolicy := os.Getenv(clockPolicyEnvName); clockPolicy != "" {
+		var err error
+		clock.Policy, err = smr.ParseClockPolicy(clockPolicy)
+		if err != nil {
+			log.Fatalf("Could not parse %s: %v", clockPolicyEnvName, err)
 		}
-
-		// Instead of resetting the crc and telegram object here (if the first character is 0x2f), we do this at the
-		// start of this function and after receiving the checksum of the telegram (whether it is valid or not).
-		// if bytes[0] == 0x2f {
-		//	crc = 0
-		//	telegram = &smr.Telegram{}
-		// }
-
-		if bytes[0] != 0x21 {
-			crc = crc16(crc, bytes)
-			parseLine(telegram, string(bytes))
-			continue
+	}
+	if maxDrift := os.Getenv(clockMaxDriftEnvName); maxDrift != "" {
+		var err error
+		clock.MaxDrift, err = time.ParseDuration(maxDrift)
+		if err != nil {
+			log.Fatalf("Could not parse %s '%s'", clockMaxDriftEnvName, maxDrift)
 		}
+	}
 
-		// When we get here, the line received contains a checksum and the telegram is finished
+	// The serial port is either a serial device or the url of a P1-to-Ethernet bridge (tcp://host:port) or a
+	// capture to replay (replay://file), the device may be a glob pattern like /dev/serial/by-id/usb-FTDI_*
+	p1, err := source.Open(serialPort, serialOptions, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		crc = crc16(crc, []byte{0x21})
-		expectedCRC, err := strconv.ParseUint(string(bytes[1:5]), 16, 16)
+	// Record the raw bytes, e.g. to reproduce a parser bug by replaying them (replay://file?speed=max)
+	if captureFile := os.Getenv(captureFileEnvName); captureFile != "" {
+		capture, err := source.NewCaptureReader(p1, captureFile)
 		if err != nil {
-			log.Error(err)
-			continue
+			log.Fatal(err)
 		}
+		defer capture.Close()
+		p1 = capture
+	}
 
-		if uint16(expectedCRC) != crc {
-			log.Error("CRC mismatch")
-
-			// Reset the crc and telegram object and continue, better luck next telegram
-			crc = 0
-			telegram = &smr.Telegram{}
-			continue
-		}
+	reader := bufio.NewReader(p1)
 
-		// The telegram is valid; send it to the channel
-		ch <- *telegram
+	go output(channel)
 
-		// Reset the crc and telegram object
-		crc = 0
-		telegram = &smr.Telegram{}
+	if err := dsmr.ReadTelegramStream(reader, channel, dsmr.Config{Version: dsmrVersion, Location: location, Clock: clock}); err != nil {
+		log.Fatal(err)
 	}
 }
 
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+	_ "time/tzdata"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/gmulders/smart-meter-readings/bus"
+	"github.com/gmulders/smart-meter-readings/dsmr"
+	"github.com/gmulders/smart-meter-readings/powercontrol"
+	"github.com/gmulders/smart-meter-readings/source"
+	"github.com/gmulders/smart-meter-readings/sunspec"
+	"github.com/gmulders/smart-meter-readings/zeroexport"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	serialPortEnvName    = "SERIAL_PORT"
+	dsmrVersionEnvName   = "DSMR_VERSION"
+	modbusUrlEnvName     = "MODBUS_URL"
+	exportLimitEnvName   = "EXPORT_LIMIT"
+	hysteresisEnvName    = "EXPORT_HYSTERESIS"
+	minIntervalEnvName   = "EXPORT_MIN_INTERVAL"
+	maxStepEnvName       = "EXPORT_MAX_STEP"
+	staleTimeoutEnvName  = "EXPORT_STALE_TIMEOUT"
+	failsafeLimitEnvName = "EXPORT_FAILSAFE_LIMIT"
+	nominalPowerEnvName  = "INVERTER_NOMINAL_POWER"
+	auditLogEnvName      = "POWER_CONTROL_AUDIT_LOG"
+	dryRunEnvName        = "DRY_RUN"
+)
+
+func main() {
+	serialPort := os.Getenv(serialPortEnvName)
+	if serialPort == "" {
+		log.Fatalf("Empty environment property %s '%s'", serialPortEnvName, serialPort)
+	}
+
+	dsmrVersion := dsmr.VersionAuto
+	if dsmrVersionString := os.Getenv(dsmrVersionEnvName); dsmrVersionString != "" {
+		var err error
+		dsmrVersion, err = dsmr.ParseVersion(dsmrVersionString)
+		if err != nil {
+			log.Fatalf("Could not parse %s '%s'", dsmrVersionEnvName, dsmrVersionString)
+		}
+	}
+
+	serialOptions, err := source.SerialOptionsFromEnv(*dsmrVersion.SerialConfig(serialPort))
+	if err != nil {
+		log.Fatal(err)
+	}
+	serialOptions.Probe = dsmr.ContainsTelegram
+
+	modbusUrl := os.Getenv(modbusUrlEnvName)
+	if modbusUrl == "" {
+		log.Fatalf("Empty environment property %s '%s'", modbusUrlEnvName, modbusUrl)
+	}
+
+	modbusBus, units, err := bus.Open(modbusUrl, 1*time.Second, nil)
+	if err != nil {
+		log.Fatalf("Could not parse %s: %v", modbusUrlEnvName, err)
+	}
+	if len(units) != 1 {
+		log.Fatalf("Only a single unit id can be given in %s", modbusUrlEnvName)
+	}
+	inverter := modbusBus.Unit(units[0])
+
+	device, err := sunspec.Discover(inverter)
+	if err != nil {
+		log.Fatalf("Could not discover the SunSpec models of the inverter: %v", err)
+	}
+	model, ok := device.Find(101, 102, 103, 111, 112, 113)
+	if !ok {
+		log.Fatal("The device has no inverter model")
+	}
+
+	config := zeroexport.DefaultConfig(0)
+	config.NominalPower = getInt64(nominalPowerEnvName, 0)
+	if config.NominalPower == 0 {
+		config.NominalPower, err = readNominalPower(inverter, device)
+		if err != nil {
+			log.Fatalf("Could not read the nominal power of the inverter, set %s: %v", nominalPowerEnvName, err)
+		}
+	}
+	config.ExportLimit = getInt64(exportLimitEnvName, 0)
+	config.Hysteresis = getInt64(hysteresisEnvName, config.Hysteresis)
+	config.MinInterval = getDuration(minIntervalEnvName, config.MinInterval)
+	config.StaleTimeout = getDuration(staleTimeoutEnvName, config.StaleTimeout)
+	config.MaxStep = getPercentage(maxStepEnvName, config.MaxStep)
+	config.FailsafeLimit = getPercentage(failsafeLimitEnvName, config.FailsafeLimit)
+	log.Infof("Keeping the export below %d W with an inverter of %d W", config.ExportLimit, config.NominalPower)
+
+	var limiter zeroexport.Limiter
+	if dryRun, _ := strconv.ParseBool(os.Getenv(dryRunEnvName)); dryRun {
+		log.Info("Dry run, the limit of the inverter is not changed")
+		limiter = dryRunLimiter{}
+	} else {
+		audit, err := powercontrol.OpenAuditLog(os.Getenv(auditLogEnvName))
+		if err != nil {
+			log.Fatal(err)
+		}
+		l := powercontrol.NewLimiter(inverter, audit)
+		l.Timeout = config.LimitDuration
+		// A limit that is left behind by a previous run has no watchdog, so it is cleared
+		if err := l.Clear("startup"); err != nil {
+			log.Fatalf("Could not clear the active power limit: %v", err)
+		}
+		limiter = l
+	}
+	controller := zeroexport.NewController(config, limiter)
+
+	p1, err := source.Open(serialPort, serialOptions, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Exiting would leave the last limit in the inverter, so when the stream ends no readings are received and the
+	// controller sets the failsafe limit
+	channel := make(chan smr.Telegram)
+	go func() {
+		defer close(channel)
+		if err := dsmr.ReadTelegramStream(bufio.NewReader(p1), channel, dsmr.Config{Version: dsmrVersion}); err != nil {
+			log.Errorf("Could not read the telegrams: %v", err)
+			return
+		}
+		log.Error("End of stream")
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case telegram, ok := <-channel:
+			if !ok {
+				channel = nil
+				continue
+			}
+			block, err := sunspec.Read(inverter, model)
+			if err != nil {
+				// Without the production of the inverter the reading is skipped, the failsafe is only based on the
+				// readings of the meter as the limit cannot be set when the inverter cannot be reached
+				log.Errorf("Could not read the inverter: %v", err)
+				continue
+			}
+			controller.Update(time.Now(), telegram.PowerDelivery-telegram.PowerConsumption, block.Int64("W", 0))
+		case now := <-ticker.C:
+			controller.Check(now)
+		}
+	}
+}
+
+// readNominalPower reads the nominal power from the nameplate (model 120) of the inverter
+func readNominalPower(inverter *bus.Unit, device *sunspec.Device) (int64, error) {
+	h, ok := device.Find(120)
+	if !ok {
+		return 0, errors.New("the inverter has no nameplate model")
+	}
+	block, err := sunspec.Read(inverter, h)
+	if err != nil {
+		return 0, err
+	}
+	power := block.Int64("WRtg", 0)
+	if power <= 0 {
+		return 0, errors.New("the nameplate has no power rating")
+	}
+	return power, nil
+}
+
+// dryRunLimiter logs the limits instead of setting them
+type dryRunLimiter struct{}
+
+func (dryRunLimiter) Set(source string, limit uint16, duration time.Duration) error {
+	log.Infof("Dry run: setting the limit to %d%% for %s", limit, duration)
+	return nil
+}
+
+func getInt64(name string, defaultValue int64) int64 {
+	s := os.Getenv(name)
+	if s == "" {
+		return defaultValue
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		log.Fatalf("Could not parse %s '%s'", name, s)
+	}
+	return v
+}
+
+func getPercentage(name string, defaultValue uint16) uint16 {
+	v := getInt64(name, int64(defaultValue))
+	if v < 0 || v > 100 {
+		log.Fatalf("%s must be between 0 and 100", name)
+	}
+	return uint16(v)
+}
+
+func getDuration(name string, defaultValue time.Duration) time.Duration {
+	s := os.Getenv(name)
+	if s == "" {
+		return defaultValue
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		log.Fatalf("Could not parse %s '%s'", name, s)
+	}
+	return v
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/gmulders/smart-meter-readings/sunspec"
+	"github.com/simonvetter/modbus"
+	log "github.com/sirupsen/logrus"
+)
+
+// batteryDiscovery finds the battery (SolarEdge StorEdge) that is attached to the inverter. The discovery is done once,
+// when no battery is found the inverter is not asked again.
+type batteryDiscovery struct {
+	done   bool
+	found  bool
+	serial string
+}
+
+// discover returns whether a battery was found
+func (d *batteryDiscovery) discover(client sunspec.Reader) (bool, error) {
+	if d.done {
+		return d.found, nil
+	}
+
+	// The first batch contains the name plate of the battery, it is only read once
+	values, err := readModbusRegisterBatches(client, BatteryRegisters, 1)
+	if errors.Is(err, modbus.ErrIllegalDataAddress) {
+		values, err = &ModbusRegisterValues{}, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// The registers of a battery that is not present are 0xffff (NaN for the floats)
+	d.found = false
+	if _, ok := (*values)["b_rated_energy"]; ok {
+		ratedEnergy := values.getFloatAsInt64("b_rated_energy", 0)
+		d.found = ratedEnergy > 0
+		if d.found {
+			d.serial, _ = (*values)["b_serialnumber"].Value.(string)
+			log.Infof("Found a %s %s battery (serial %s) of %d Wh", (*values)["b_manufacturer"].Value,
+				(*values)["b_model"].Value, (*values)["b_serialnumber"].Value, ratedEnergy)
+		}
+	}
+	if !d.found {
+		log.Info("No battery found")
+	}
+	d.done = true
+	return d.found, nil
+}
+
+// readBattery reads the battery, when one is attached to the inverter, and sends the readout to ch
+func readBattery(d *Device, battery *batteryDiscovery, timestamp time.Time, ch chan smr.BatteryReadout) {
+	ok, err := battery.discover(d.unit)
+	if err != nil {
+		log.Errorf("%s: could not discover the battery: %v", d.Name, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	readout, err := ReadBatteryMeasurement(d.unit)
+	if err != nil {
+		log.Errorf("%s: could not read the battery: %v", d.Name, err)
+		return
+	}
+	readout.Timestamp = timestamp
+	readout.Device = d.source("battery")
+	readout.Serial = battery.serial
+
+	ch <- *readout
+}
+
+func ReadBatteryMeasurement(client sunspec.Reader) (*smr.BatteryReadout, error) {
+	values, err := readModbusRegisterBatches(client, BatteryRegisters, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	measurement := &smr.BatteryReadout{}
+	measurement.Power = values.getFloatAsInt64("b_power", 0)
+	measurement.Voltage = values.getFloatAsInt64("b_voltage", 3)
+	measurement.Current = values.getFloatAsInt64("b_current", 3)
+	measurement.Temperature = values.getFloatAsInt64("b_temperature", 2)
+	measurement.MaxTemperature = values.getFloatAsInt64("b_max_temperature", 2)
+	measurement.StateOfCharge = values.getFloatAsInt64("b_state_of_energy", 2)
+	measurement.StateOfHealth = values.getFloatAsInt64("b_state_of_health", 2)
+	measurement.AvailableEnergy = values.getFloatAsInt64("b_available_energy", 0)
+	measurement.MaxEnergy = values.getFloatAsInt64("b_max_energy", 0)
+	measurement.Charged = values.getInt64("b_imported")
+	measurement.Discharged = values.getInt64("b_exported")
+	measurement.Status = values.getInt64("b_status")
+
+	return measurement, nil
+}
+
+// BatteryRegisters are the registers of the first battery. The registers in this range are stored with the least
+// significant register first.
+var BatteryRegisters = []ModbusRegister{
+	{"b_manufacturer", 0xe100, 16, STRING, "Manufacturer", "", 1},
+	{"b_model", 0xe110, 16, STRING, "Model", "", 1},
+	{"b_version", 0xe120, 16, STRING, "Firmware Version", "", 1},
+	{"b_serialnumber", 0xe130, 16, STRING, "Serial", "", 1},
+	{"b_deviceaddress", 0xe140, 1, UINT16, "Device ID", "", 1},
+	{"b_rated_energy", 0xe142, 2, FLOAT32, "Rated Energy", "Wh", 1},
+	{"b_max_charge_power", 0xe144, 2, FLOAT32, "Max Charge Continuous Power", "W", 1},
+	{"b_max_discharge_power", 0xe146, 2, FLOAT32, "Max Discharge Continuous Power", "W", 1},
+	{"b_max_charge_peak_power", 0xe148, 2, FLOAT32, "Max Charge Peak Power", "W", 1},
+	{"b_max_discharge_peak_power", 0xe14a, 2, FLOAT32, "Max Discharge Peak Power", "W", 1},
+
+	{"b_temperature", 0xe16c, 2, FLOAT32, "Average Temperature", "°C", 2},
+	{"b_max_temperature", 0xe16e, 2, FLOAT32, "Max Temperature", "°C", 2},
+	{"b_voltage", 0xe170, 2, FLOAT32, "Instantaneous Voltage", "V", 2},
+	{"b_current", 0xe172, 2, FLOAT32, "Instantaneous Current", "A", 2},
+	{"b_power", 0xe174, 2, FLOAT32, "Instantaneous Power", "W", 2},
+	{"b_exported", 0xe176, 4, UINT64, "Lifetime Export Energy Counter", "Wh", 2},
+	{"b_imported", 0xe17a, 4, UINT64, "Lifetime Import Energy Counter", "Wh", 2},
+	{"b_max_energy", 0xe17e, 2, FLOAT32, "Max Energy", "Wh", 2},
+	{"b_available_energy", 0xe180, 2, FLOAT32, "Available Energy", "Wh", 2},
+	{"b_state_of_health", 0xe182, 2, FLOAT32, "State of Health", "%", 2},
+	{"b_state_of_energy", 0xe184, 2, FLOAT32, "State of Energy", "%", 2},
+	{"b_status", 0xe186, 2, UINT32, "Status", "", 2},
+	{"b_status_internal", 0xe188, 2, UINT32, "Internal Status", "", 2},
+}
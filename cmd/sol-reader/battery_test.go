@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/simonvetter/modbus"
+)
+
+// inverter holds the holding registers of an inverter, reading an address that is not in the map fails
+type inverter map[uint16]uint16
+
+func (r inverter) ReadRegisters(address uint16, quantity uint16, regType modbus.RegType) ([]uint16, error) {
+	result := make([]uint16, quantity)
+	for i := range result {
+		v, ok := r[address+uint16(i)]
+		if !ok {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		result[i] = v
+	}
+	return result, nil
+}
+
+// battery returns an inverter with the battery registers set to 0xffff, which is what an inverter without a battery
+// returns
+func battery() inverter {
+	r := inverter{}
+	for address := uint16(0xe100); address < 0xe18a; address++ {
+		r[address] = 0xffff
+	}
+	return r
+}
+
+// set sets the words of the register, the words are given in the order in which they are stored
+func (r inverter) set(name string, words ...uint16) {
+	for _, b := range BatteryRegisters {
+		if b.Name == name {
+			for i, w := range words {
+				r[b.Address+uint16(i)] = w
+			}
+			return
+		}
+	}
+	panic("unknown register " + name)
+}
+
+func TestReadBatteryMeasurement(t *testing.T) {
+	r := battery()
+	// The least significant word of the FLOAT32 and UINT64 registers is stored first
+	r.set("b_power", 0x4ccd, 0xc49c)           // -1250.4 W
+	r.set("b_voltage", 0x199a, 0x43c7)         // 398.2 V
+	r.set("b_current", 0xf5c3, 0xc048)         // -3.14 A
+	r.set("b_temperature", 0x0000, 0x41da)     // 27.25 °C
+	r.set("b_state_of_energy", 0x0000, 0x4252) // 52.5 %
+	r.set("b_state_of_health", 0x0000, 0x42c7) // 99.5 %
+	r.set("b_max_energy", 0x9000, 0x4617)      // 9700 Wh
+	r.set("b_available_energy", 0x9000, 0x4617)
+	r.set("b_imported", 0x0004, 0x0003, 0x0002, 0x0001)
+	r.set("b_exported", 0x5678, 0x1234, 0x0000, 0x0000)
+	r.set("b_status", 0x0003, 0x0000)
+
+	readout, err := ReadBatteryMeasurement(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// b_max_temperature is NaN, which is read as 0
+	expected := smr.BatteryReadout{
+		Power:           -1250,
+		Voltage:         398200,
+		Current:         -3140,
+		Temperature:     2725,
+		MaxTemperature:  0,
+		StateOfCharge:   5250,
+		StateOfHealth:   9950,
+		AvailableEnergy: 9700,
+		MaxEnergy:       9700,
+		Charged:         0x0001000200030004,
+		Discharged:      0x12345678,
+		Status:          3,
+	}
+	if *readout != expected {
+		t.Errorf("expected %+v, got %+v", expected, *readout)
+	}
+}
+
+func TestDiscoverBattery(t *testing.T) {
+	present := battery()
+	present.set("b_manufacturer", 0x4c47, 0x0000) // "LG", the strings are padded with zeros
+	present.set("b_serialnumber", 0x3132, 0x3334, 0x0000)
+	present.set("b_rated_energy", 0x9000, 0x4617)
+
+	zero := battery()
+	zero.set("b_rated_energy", 0x0000, 0x0000)
+
+	tests := []struct {
+		name   string
+		r      inverter
+		found  bool
+		serial string
+	}{
+		{"battery", present, true, "1234"},
+		// The rated energy of an inverter without a battery is NaN
+		{"not implemented", battery(), false, ""},
+		{"zero rated energy", zero, false, ""},
+		// Some inverters do not have the battery registers at all
+		{"no registers", inverter{}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &batteryDiscovery{}
+			found, err := d.discover(tt.r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != tt.found || d.serial != tt.serial {
+				t.Errorf("expected found %v serial '%s', got %v '%s'", tt.found, tt.serial, found, d.serial)
+			}
+
+			// The battery is only discovered once
+			delete(tt.r, 0xe142)
+			if found, err := d.discover(tt.r); err != nil || found != tt.found {
+				t.Errorf("expected the discovery to be remembered, got %v %v", found, err)
+			}
+		})
+	}
+}
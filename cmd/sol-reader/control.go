@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/gmulders/smart-meter-readings/bus"
+	"github.com/gmulders/smart-meter-readings/powercontrol"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	powerControlHttpAddrEnvName  = "POWER_CONTROL_HTTP_ADDR"
+	powerControlHttpTokenEnvName = "POWER_CONTROL_HTTP_TOKEN"
+	powerControlMqttTopicEnvName = "POWER_CONTROL_MQTT_TOPIC"
+	powerControlMinLimitEnvName  = "POWER_CONTROL_MIN_LIMIT"
+	powerControlTimeoutEnvName   = "POWER_CONTROL_TIMEOUT"
+	powerControlAuditLogEnvName  = "POWER_CONTROL_AUDIT_LOG"
+)
+
+// startPowerControl starts the HTTP server and the MQTT subscription that control the active power limit of the
+// inverter, when they are configured
+func startPowerControl(ctx context.Context, client *bus.Unit) {
+	httpAddr := os.Getenv(powerControlHttpAddrEnvName)
+	mqttTopic := os.Getenv(powerControlMqttTopicEnvName)
+	if httpAddr == "" && mqttTopic == "" {
+		return
+	}
+
+	audit, err := powercontrol.OpenAuditLog(os.Getenv(powerControlAuditLogEnvName))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	limiter := powercontrol.NewLimiter(client, audit)
+	if minLimit := os.Getenv(powerControlMinLimitEnvName); minLimit != "" {
+		limit, err := strconv.ParseUint(minLimit, 10, 8)
+		if err != nil || limit > uint64(powercontrol.NoLimit) {
+			log.Fatalf("Could not parse %s '%s'", powerControlMinLimitEnvName, minLimit)
+		}
+		limiter.Min = uint16(limit)
+	}
+	if timeout := os.Getenv(powerControlTimeoutEnvName); timeout != "" {
+		limiter.Timeout, err = time.ParseDuration(timeout)
+		if err != nil || limiter.Timeout <= 0 {
+			log.Fatalf("Could not parse %s '%s'", powerControlTimeoutEnvName, timeout)
+		}
+	}
+
+	// A limit that is left behind by a previous run has no watchdog, so it is cleared
+	if err := limiter.Clear("startup"); err != nil {
+		log.Errorf("Could not clear the active power limit: %v", err)
+	}
+
+	if httpAddr != "" {
+		limiter.Token = os.Getenv(powerControlHttpTokenEnvName)
+		httpAddr, err = listenAddr(httpAddr, limiter.Token)
+		if err != nil {
+			log.Fatalf("Could not start power control: %v", err)
+		}
+
+		router := httprouter.New()
+		limiter.Register(router)
+		go func() {
+			log.Infof("Starting power control on %s", httpAddr)
+			if err := http.ListenAndServe(httpAddr, router); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	if mqttTopic != "" {
+		config := smr.BuildPahoClientConfig()
+		config.OnConnectionUp = func(cm *autopaho.ConnectionManager, connAck *paho.Connack) {
+			log.Info("mqtt connection up")
+			if _, err := cm.Subscribe(context.Background(), &paho.Subscribe{
+				Subscriptions: map[string]paho.SubscribeOptions{
+					mqttTopic: {QoS: 1}, // At least once
+				},
+			}); err != nil {
+				log.Errorf("failed to subscribe (%s). No power control commands will be received.", err)
+				return
+			}
+			log.Info("mqtt subscription made")
+		}
+		config.ClientConfig.Router = paho.NewSingleHandlerRouter(limiter.HandleMessage)
+
+		// Connect to the broker - this will return immediately after initiating the connection process
+		if _, err := autopaho.NewConnection(ctx, config); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// listenAddr returns the address on which the power control server listens. The commands change the output of the
+// inverter, so without a host the server only listens on localhost and without a token it refuses to listen on any
+// other address.
+func listenAddr(addr string, token string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("could not parse %s '%s'", powerControlHttpAddrEnvName, addr)
+	}
+	if host == "" {
+		return net.JoinHostPort("localhost", port), nil
+	}
+	if ip := net.ParseIP(host); token == "" && host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return "", fmt.Errorf("%s is not a loopback address and %s is not set", addr, powerControlHttpTokenEnvName)
+	}
+	return addr, nil
+}
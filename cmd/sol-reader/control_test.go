@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestListenAddr(t *testing.T) {
+	tests := []struct {
+		addr     string
+		token    string
+		expected string
+		refused  bool
+	}{
+		{":8080", "", "localhost:8080", false},
+		{"localhost:8080", "", "localhost:8080", false},
+		{"127.0.0.1:8080", "", "127.0.0.1:8080", false},
+		{"[::1]:8080", "", "[::1]:8080", false},
+		// Without a token the inverter could be controlled by anyone on the network
+		{"0.0.0.0:8080", "", "", true},
+		{"192.168.1.10:8080", "", "", true},
+		{"inverter.local:8080", "", "", true},
+		{"0.0.0.0:8080", "secret", "0.0.0.0:8080", false},
+		{"8080", "secret", "", true},
+	}
+	for _, tt := range tests {
+		addr, err := listenAddr(tt.addr, tt.token)
+		if (err != nil) != tt.refused || addr != tt.expected {
+			t.Errorf("%s (token '%s'): expected '%s' refused %v, got '%s' %v", tt.addr, tt.token, tt.expected,
+				tt.refused, addr, err)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/gmulders/smart-meter-readings/bus"
+	"github.com/gmulders/smart-meter-readings/registermap"
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultInterval      = 10 * time.Second
+	defaultNightInterval = 5 * time.Minute
+	modbusTimeout        = 1 * time.Second
+)
+
+// Device is a device that is polled by sol-reader, either a SunSpec device or a device that is read with a register
+// map
+type Device struct {
+	// Name is the source tag of the points of the device
+	Name string `json:"name" yaml:"name"`
+	URL  string `json:"url" yaml:"url"`
+	// UnitID overrides the unit id of the url
+	UnitID uint8 `json:"unit_id,omitempty" yaml:"unit_id,omitempty"`
+	// Interval is the time between two polls, e.g. `5s`
+	Interval string `json:"interval,omitempty" yaml:"interval,omitempty"`
+	// NightInterval is the longest time between two reads of a SunSpec inverter while it is sleeping or off
+	NightInterval string `json:"night_interval,omitempty" yaml:"night_interval,omitempty"`
+	// RegisterMap is the name of a built-in register map or a file, a device without a register map is read as a
+	// SunSpec device
+	RegisterMap string `json:"register_map,omitempty" yaml:"register_map,omitempty"`
+	// PowerControl enables the power control of the device, see startPowerControl
+	PowerControl bool `json:"power_control,omitempty" yaml:"power_control,omitempty"`
+
+	// stream is appended to the names of the files the readouts are written to, so every device writes files of its
+	// own
+	stream        string
+	interval      time.Duration
+	nightInterval time.Duration
+	unit          *bus.Unit
+	registerMap   *registermap.Map
+}
+
+// loadDevices loads the devices from a YAML or JSON file (.json):
+//
+//	devices:
+//	  - {name: roof-east, url: "tcp://192.168.1.127:1502", interval: 10s, night_interval: 5m, power_control: true}
+//	  - {name: grid, url: "rtu:///dev/ttyUSB0?baud=9600", unit_id: 2, register_map: sdm630, interval: 5s}
+func loadDevices(name string, metrics *smr.Metrics) ([]*Device, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	config := struct {
+		Devices []*Device `json:"devices" yaml:"devices"`
+	}{}
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		err = json.Unmarshal(data, &config)
+	} else {
+		err = yaml.Unmarshal(data, &config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(config.Devices) == 0 {
+		return nil, fmt.Errorf("%s: no devices", name)
+	}
+
+	names := map[string]bool{}
+	powerControl := false
+	for _, d := range config.Devices {
+		if d.Name == "" {
+			return nil, fmt.Errorf("%s: device %s has no name", name, d.URL)
+		}
+		if names[d.Name] {
+			return nil, fmt.Errorf("%s: device %s is defined twice", name, d.Name)
+		}
+		names[d.Name] = true
+		d.stream = d.Name
+
+		if err := d.init(metrics); err != nil {
+			return nil, fmt.Errorf("%s: device %s: %w", name, d.Name, err)
+		}
+		if d.PowerControl {
+			if d.registerMap != nil || powerControl {
+				return nil, fmt.Errorf("%s: power control is only supported for a single SunSpec device", name)
+			}
+			powerControl = true
+		}
+	}
+	return config.Devices, nil
+}
+
+// envDevices returns the device at MODBUS_URL, or a device for each of its unit ids when it is read with the register
+// map of MODBUS_REGISTER_MAP. The readouts are written to the same files as before the devices could be configured.
+func envDevices(metrics *smr.Metrics) ([]*Device, error) {
+	modbusUrl := os.Getenv(modbusUrlEnvName)
+	if modbusUrl == "" {
+		return nil, fmt.Errorf("empty environment property %s '%s'", modbusUrlEnvName, modbusUrl)
+	}
+	interval := os.Getenv(pollIntervalEnvName)
+	nightInterval := os.Getenv(nightIntervalEnvName)
+	_, units, err := bus.Open(modbusUrl, modbusTimeout, metrics)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", modbusUrlEnvName, err)
+	}
+
+	registerMap := os.Getenv(registerMapEnvName)
+	if registerMap == "" {
+		if len(units) != 1 {
+			return nil, fmt.Errorf("only a single unit id can be given in %s for a SunSpec device", modbusUrlEnvName)
+		}
+		d := &Device{Name: "solar-edge-1", URL: modbusUrl, UnitID: units[0], Interval: interval,
+			NightInterval: nightInterval, PowerControl: true}
+		return []*Device{d}, d.init(metrics)
+	}
+
+	devices := make([]*Device, 0, len(units))
+	for _, id := range units {
+		d := &Device{URL: modbusUrl, UnitID: id, Interval: interval, RegisterMap: registerMap}
+		if err := d.init(metrics); err != nil {
+			return nil, err
+		}
+		d.Name = d.registerMap.Name
+		if len(units) > 1 {
+			d.Name = fmt.Sprintf("%s-%d", d.registerMap.Name, id)
+			d.stream = d.Name
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// init parses the intervals, opens the bus of the device and loads its register map
+func (d *Device) init(metrics *smr.Metrics) error {
+	var err error
+	d.interval, err = parseInterval(d.Interval, defaultInterval)
+	if err != nil {
+		return fmt.Errorf("could not parse interval '%s'", d.Interval)
+	}
+	d.nightInterval, err = parseInterval(d.NightInterval, defaultNightInterval)
+	if err != nil {
+		return fmt.Errorf("could not parse night interval '%s'", d.NightInterval)
+	}
+
+	if d.URL == "" {
+		return errors.New("no url")
+	}
+	b, units, err := bus.Open(d.URL, modbusTimeout, metrics)
+	if err != nil {
+		return err
+	}
+	if d.UnitID == 0 {
+		if len(units) != 1 {
+			return errors.New("a device has a single unit id")
+		}
+		d.UnitID = units[0]
+	}
+	d.unit = b.Unit(d.UnitID)
+
+	if d.RegisterMap != "" {
+		d.registerMap, err = registermap.Load(d.RegisterMap)
+		if err != nil {
+			return fmt.Errorf("could not load the register map %s: %w", d.RegisterMap, err)
+		}
+	}
+	return nil
+}
+
+func parseInterval(s string, defaultValue time.Duration) (time.Duration, error) {
+	if s == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d <= 0 {
+		err = errors.New("not positive")
+	}
+	return d, err
+}
+
+// source returns the source tag of the readouts of a part of the device, e.g. `roof-east-meter`. The device from
+// MODBUS_URL keeps the sources it had before the devices could be configured, like `solar-edge-meter-1`.
+func (d *Device) source(part string) string {
+	if d.stream == "" {
+		return ""
+	}
+	return d.Name + "-" + part
+}
+
+// run polls the device and writes its readouts, it does not return
+func (d *Device) run(ctx context.Context, client influxdb2.Client) {
+	if d.registerMap != nil {
+		stream := d.registerMap.Measurement
+		if d.stream != "" {
+			stream += "-" + d.stream
+		}
+		channel := make(chan smr.ModbusReadout)
+		go smr.WriteMeasurementStream[smr.ModbusReadout](ctx, channel, smr.ModbusReadoutHandler{Stream: stream}, client)
+
+		readRegisterMapStream(d, channel)
+		return
+	}
+
+	channel := make(chan smr.SolarReadout)
+	readoutChannel := make(chan smr.SolarReadout)
+	eventChannel := make(chan smr.InverterEvent)
+	meterChannel := make(chan smr.EnergyMeterReadout)
+	batteryChannel := make(chan smr.BatteryReadout)
+
+	go smr.WriteMeasurementStream[smr.SolarReadout](ctx, readoutChannel, smr.SolarReadoutHandler{Device: d.stream},
+		client)
+	go smr.WriteMeasurementStream[smr.InverterEvent](ctx, eventChannel, smr.InverterEventHandler{Device: d.stream},
+		client)
+	go smr.WriteMeasurementStream[smr.EnergyMeterReadout](ctx, meterChannel,
+		smr.EnergyMeterReadoutHandler{Device: d.stream}, client)
+	go smr.WriteMeasurementStream[smr.BatteryReadout](ctx, batteryChannel, smr.BatteryReadoutHandler{Device: d.stream},
+		client)
+	go splitReadouts(channel, readoutChannel, eventChannel)
+
+	readSolarReadoutStream(d, channel, meterChannel, batteryChannel)
+}
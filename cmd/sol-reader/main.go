@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"math"
 	"os"
 	"strings"
 	"time"
 
 	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/gmulders/smart-meter-readings/bus"
+	"github.com/gmulders/smart-meter-readings/sunspec"
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 	retry "github.com/sethvargo/go-retry"
 	"github.com/simonvetter/modbus"
@@ -17,26 +20,19 @@ import (
 )
 
 const (
+	devicesEnvName         = "SOL_READER_DEVICES"
 	modbusUrlEnvName       = "MODBUS_URL"
+	registerMapEnvName     = "MODBUS_REGISTER_MAP"
+	pollIntervalEnvName    = "POLL_INTERVAL"
+	nightIntervalEnvName   = "NIGHT_INTERVAL"
 	influxServerUrlEnvName = "INLFUX_SERVER_URL"
 	influxAuthTokenEnvName = "INLFUX_AUTH_TOKEN"
 )
 
 func main() {
-
-	channel := make(chan smr.SolarReadout)
-
-	handler := smr.SolarReadoutHandler{}
-
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	//	modbusUrl := "tcp://192.168.1.127:1502"
-	modbusUrl := os.Getenv(modbusUrlEnvName)
-	if modbusUrl == "" {
-		log.Fatalf("Empty environment property %s '%s'", modbusUrlEnvName, modbusUrl)
-	}
-
 	influxServerUrl := os.Getenv(influxServerUrlEnvName)
 	if influxServerUrl == "" {
 		log.Fatalf("Empty environment property %s '%s'", influxServerUrlEnvName, influxServerUrl)
@@ -53,81 +49,232 @@ func main() {
 		influxdb2.DefaultOptions(),
 	)
 
-	// // Connect to the broker - this will return immediately after initiating the connection process
-	// cm, err := autopaho.NewConnection(ctx, config)
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
-	go smr.WriteMeasurementStream[smr.SolarReadout](ctx, channel, handler, client)
+	metrics := smr.NewMetrics("sol-reader")
+	go metrics.WriteMetricsStream(ctx, client, time.Minute)
+
+	// The devices are either listed in a file or given by MODBUS_URL (e.g. tcp://192.168.1.127:1502)
+	var devices []*Device
+	var err error
+	if devicesFile := os.Getenv(devicesEnvName); devicesFile != "" {
+		devices, err = loadDevices(devicesFile, metrics)
+	} else {
+		devices, err = envDevices(metrics)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Every device is polled on its own, the devices on the same bus wait for each other
+	for _, d := range devices {
+		if d.PowerControl {
+			startPowerControl(ctx, d.unit)
+		}
+		log.Infof("Polling %s at %s (unit %d) every %s", d.Name, d.URL, d.UnitID, d.interval)
+		go d.run(ctx, client)
+	}
 
-	readSolarReadoutStream(modbusUrl, 1*time.Second, channel)
+	<-ctx.Done()
 }
 
-func readSolarReadoutStream(url string, timeout time.Duration, ch chan smr.SolarReadout) {
-	measurement := &smr.SolarReadout{}
+// splitReadouts forwards the readouts and sends an event to the event channel when the status of the inverter changes.
+// While the inverter is in fault, a change of the vendor status (the fault code) is sent as an event as well.
+func splitReadouts(ch chan smr.SolarReadout, readoutCh chan smr.SolarReadout, eventCh chan smr.InverterEvent) {
+	status := smr.InverterStatusUndefined
+	var vendorStatus int64
 
-	client, err := modbus.NewClient(&modbus.ClientConfiguration{
-		URL:     url,
-		Timeout: timeout,
-	})
-	if err != nil {
-		log.Fatal("could not create a new client", err)
+	for {
+		readout := <-ch
+
+		readoutCh <- readout
+
+		if readout.Status == status && (status != smr.InverterStatusFault || readout.VendorStatus == vendorStatus) {
+			continue
+		}
+
+		if readout.Status == smr.InverterStatusFault {
+			log.Errorf("Inverter status changed from %s to %s, vendor status %d", status, readout.Status,
+				readout.VendorStatus)
+		} else {
+			log.Infof("Inverter status changed from %s to %s", status, readout.Status)
+		}
+
+		eventCh <- smr.InverterEvent{
+			Timestamp:    readout.Timestamp,
+			Previous:     status,
+			Status:       readout.Status,
+			VendorStatus: readout.VendorStatus,
+			Device:       readout.Device,
+			Serial:       readout.Serial,
+		}
+		status = readout.Status
+		vendorStatus = readout.VendorStatus
 	}
+}
+
+// readSolarReadoutStream polls the inverter and sends the readouts to ch. When an energy meter or a battery is attached
+// to the inverter, their readouts are sent to meterCh and batteryCh.
+func readSolarReadoutStream(d *Device, ch chan smr.SolarReadout,
+	meterCh chan smr.EnergyMeterReadout, batteryCh chan smr.BatteryReadout) {
+	measurement := &smr.SolarReadout{}
+	battery := &batteryDiscovery{}
+	var device *sunspecDevice
+	status := smr.InverterStatusUndefined
+	schedule := &inverterSchedule{interval: d.interval, nightInterval: d.nightInterval}
 
 	strategy := retry.WithMaxRetries(8, retry.NewFibonacci(100*time.Millisecond))
 
 	for {
 		retry.Do(context.Background(), strategy, func(ctx context.Context) error {
-			err := client.Open()
-			if err != nil {
-				log.Errorf("could not connect to the inverter: %v", err)
-				return retry.RetryableError(err)
+			var err error
+			if device == nil {
+				device, err = discoverDevice(d.unit)
+				if err != nil {
+					log.Errorf("%s: could not discover the SunSpec models: %v", d.Name, err)
+					return retry.RetryableError(err)
+				}
 			}
-			defer client.Close()
-			measurement, err = ReadSolarMeasurement(client)
-			if err != nil {
-				log.Errorf("could not read the measurement: %v", err)
-				return retry.RetryableError(err)
+
+			now := time.Now()
+			if schedule.due(now) {
+				measurement, err = ReadSolarMeasurement(d.unit, device.Device)
+				if err != nil {
+					log.Errorf("%s: could not read the measurement: %v", d.Name, err)
+					return retry.RetryableError(err)
+				}
+				measurement.Device = d.Name
+				measurement.Serial = device.serial
+
+				// While the inverter is asleep only the readout with the change of the status is written, the others
+				// are all zero
+				if !asleep(measurement.Status) || measurement.Status != status {
+					ch <- *measurement
+				}
+				status = measurement.Status
+				schedule.read(now, status)
 			}
 
-			ch <- *measurement
+			// The meter and the battery are optional, a failure to read them does not fail the readout of the inverter.
+			// They are read at the interval of the device, also while the inverter is asleep.
+			readEnergyMeter(d, device, now, meterCh)
+			readBattery(d, battery, now, batteryCh)
 			return nil
 		})
 
-		time.Sleep(10 * time.Second)
+		time.Sleep(d.interval)
+	}
+}
+
+// sunspecDevice is a discovered SunSpec device with the serial numbers of the inverter and the meter
+type sunspecDevice struct {
+	*sunspec.Device
+	serial      string
+	meterSerial string
+}
+
+// discoverDevice finds the SunSpec models of the device and logs them
+func discoverDevice(client *bus.Unit) (*sunspecDevice, error) {
+	device, err := sunspec.Discover(client)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range device.Models {
+		log.Infof("Found %s (%d) at address %d", h.Name(), h.ID, h.Address)
+	}
+
+	result := &sunspecDevice{Device: device}
+	if h, ok := device.Find(101, 102, 103, 111, 112, 113); ok {
+		result.serial, err = readSerial(client, device, h)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if h, ok := device.Find(201, 202, 203, 204); ok {
+		result.meterSerial, err = readSerial(client, device, h)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// readSerial reads the serial number from the common model that describes the model, and logs the device
+func readSerial(client *bus.Unit, device *sunspec.Device, h sunspec.Header) (string, error) {
+	c, ok := device.Common(h)
+	if !ok {
+		return "", nil
+	}
+	common, err := sunspec.Read(client, c)
+	if err != nil {
+		return "", err
 	}
+	log.Infof("Device %s %s (serial %s, version %s)", common.Text("Mn"), common.Text("Md"), common.Text("SN"),
+		common.Text("Vr"))
+	return common.Text("SN"), nil
 }
 
-func ReadSolarMeasurement(client *modbus.ModbusClient) (measurement *smr.SolarReadout, err error) {
-	values, err := ReadModbusRegisterValues(client)
+func ReadSolarMeasurement(client *bus.Unit, device *sunspec.Device) (measurement *smr.SolarReadout, err error) {
+	h, ok := device.Find(101, 102, 103, 111, 112, 113)
+	if !ok {
+		return nil, errors.New("the device has no inverter model")
+	}
+	block, err := sunspec.Read(client, h)
 	if err != nil {
 		return
 	}
 
-	for a, b := range *values {
-		log.Infof("%s -> %v\n", a, b)
+	for a, b := range block.Values {
+		log.Debugf("%s -> %v\n", a, b)
 	}
 
 	measurement = &smr.SolarReadout{}
+	// The SunSpec models have no clock of the inverter, so the readouts have the time of the host
 	measurement.Timestamp = time.Now()
-	measurement.Current = values.getScaledInt64("current", "current_scale", 3)
-	measurement.L1Current = values.getScaledInt64("l1_current", "current_scale", 3)
-	measurement.L1Voltage = values.getScaledInt64("l1_voltage", "voltage_scale", 3)
-	measurement.L1NVoltage = values.getScaledInt64("l1n_voltage", "voltage_scale", 3)
-	measurement.PowerAC = values.getScaledInt64("power_ac", "power_ac_scale", 0)
-	measurement.Frequency = values.getScaledInt64("frequency", "frequency_scale", 3)
-	measurement.PowerApparent = values.getScaledInt64("power_apparent", "power_apparent_scale", 0)
-	measurement.PowerReactive = values.getScaledInt64("power_reactive", "power_reactive_scale", 0)
-	measurement.PowerFactor = values.getScaledInt64("power_factor", "power_factor_scale", 2)
-	measurement.EnergyTotal = values.getScaledInt64("energy_total", "energy_total_scale", 0)
-	measurement.CurrentDC = values.getScaledInt64("current_dc", "current_dc_scale", 3)
-	measurement.VoltageDC = values.getScaledInt64("voltage_dc", "voltage_dc_scale", 3)
-	measurement.PowerDC = values.getScaledInt64("power_dc", "power_dc_scale", 0)
-	measurement.Temperature = values.getScaledInt64("temperature", "temperature_scale", 2)
+	measurement.Current = block.Int64("A", 3)
+	measurement.L1Current = block.Int64("AphA", 3)
+	measurement.L1Voltage = block.Int64("PPVphAB", 3)
+	measurement.L1NVoltage = block.Int64("PhVphA", 3)
+	measurement.PowerAC = block.Int64("W", 0)
+	measurement.Frequency = block.Int64("Hz", 3)
+	measurement.PowerApparent = block.Int64("VA", 0)
+	measurement.PowerReactive = block.Int64("VAr", 0)
+	measurement.PowerFactor = block.Int64("PF", 2)
+	measurement.EnergyTotal = block.Int64("WH", 0)
+	measurement.CurrentDC = block.Int64("DCA", 3)
+	measurement.VoltageDC = block.Int64("DCV", 3)
+	measurement.PowerDC = block.Int64("DCW", 0)
+	measurement.Temperature = block.Int64("TmpSnk", 2)
+
+	measurement.Status = smr.InverterStatus(block.Int64("St", 0))
+	measurement.VendorStatus = block.Int64("StVnd", 0)
+
+	// The registers of the phases that the inverter does not have are not implemented
+	measurement.Phases = phases(h.ID)
+	if measurement.Phases >= 2 {
+		measurement.L2Current = block.Int64("AphB", 3)
+		measurement.L2Voltage = block.Int64("PPVphBC", 3)
+		measurement.L2NVoltage = block.Int64("PhVphB", 3)
+	}
+	if measurement.Phases >= 3 {
+		measurement.L3Current = block.Int64("AphC", 3)
+		measurement.L3Voltage = block.Int64("PPVphCA", 3)
+		measurement.L3NVoltage = block.Int64("PhVphC", 3)
+	}
 
 	return
 }
 
+// phases returns the number of phases of the inverter with the given SunSpec model
+func phases(model uint16) int8 {
+	switch model {
+	case 102, 112:
+		return 2
+	case 103, 113:
+		return 3
+	}
+	return 1
+}
+
 type ModbusRegisterValue struct {
 	Register ModbusRegister
 	Value    interface{}
@@ -135,78 +282,69 @@ type ModbusRegisterValue struct {
 
 type ModbusRegisterValues map[string]ModbusRegisterValue
 
-func scaleInt64(number int64, scale int16) int64 {
-	if scale < 0 {
-		if -scale > 19 { // 10 ** 20 overflows
-			return 0
-		}
-		return number / int64(powUint64(10, uint64(-scale)))
-	} else {
-		if scale > 19 {
-			return math.MaxInt64
-		}
-		return number * int64(powUint64(10, uint64(scale)))
-	}
-}
-
-func powUint64(x, n uint64) uint64 {
-	if n == 0 {
-		return 1
-	}
-	if n == 1 {
-		return x
-	}
-	y := powUint64(x, n/2)
-	if n%2 == 0 {
-		return y * y
-	}
-	return x * y * y
-}
-
-func (vs *ModbusRegisterValues) getScaledInt64(key string, scaleKey string, s int16) int64 {
-	scale := s + (*vs)[scaleKey].Value.(int16)
-
+// getInt64 returns the integer value without scaling it
+func (vs *ModbusRegisterValues) getInt64(key string) int64 {
 	v := (*vs)[key]
 	var int64Val int64
 	if v.Register.DataType == INT16 {
 		int64Val = int64(v.Value.(int16))
 	} else if v.Register.DataType == UINT16 {
 		int64Val = int64(v.Value.(uint16))
-	} else if v.Register.DataType == ACC32 {
+	} else if v.Register.DataType == ACC32 || v.Register.DataType == UINT32 {
 		int64Val = int64(v.Value.(uint32))
+	} else if v.Register.DataType == UINT64 {
+		int64Val = int64(v.Value.(uint64))
 	} else {
 		log.Fatal("Impossible data type conversion")
 	}
-	return scaleInt64(int64Val, scale)
+	return int64Val
+}
+
+// getFloatAsInt64 returns the FLOAT32 value multiplied by 10^s and rounded. NaN (not implemented) is returned as 0.
+func (vs *ModbusRegisterValues) getFloatAsInt64(key string, s int) int64 {
+	v := float64((*vs)[key].Value.(float32))
+	if math.IsNaN(v) {
+		return 0
+	}
+	return int64(math.Round(v * math.Pow10(s)))
 }
 
-func ReadModbusRegisterValues(client *modbus.ModbusClient) (*ModbusRegisterValues, error) {
+// readModbusRegisterBatches reads the given batches of the registers, a batch is read in a single request
+func readModbusRegisterBatches(client sunspec.Reader, all []ModbusRegister, batches ...int) (*ModbusRegisterValues, error) {
 	var readValues ModbusRegisterValues = ModbusRegisterValues{}
 
-	for i := 1; i <= 3; i++ {
-		registers := filter(Registers, func(r ModbusRegister) bool {
+	for _, i := range batches {
+		registers := filter(all, func(r ModbusRegister) bool {
 			return r.Batch == i
 		})
-		var max = maxBy(registers, func(a ModbusRegister, b ModbusRegister) int64 {
-			return int64(a.Address) - int64(b.Address)
-		})
-		var min = maxBy(registers, func(a ModbusRegister, b ModbusRegister) int64 {
-			return int64(b.Address) - int64(a.Address)
-		})
-
-		result, err := client.ReadRegisters(min.Address, max.Address+max.Size-min.Address, modbus.HOLDING_REGISTER)
-		if err != nil {
+		if err := readModbusRegisters(client, registers, readValues); err != nil {
 			return nil, err
 		}
-
-		for _, r := range registers {
-			readValues[r.Name] = ModbusRegisterValue{r, decodeValue(min.Address, result, r)}
-		}
 	}
 
 	return &readValues, nil
 }
 
+// readModbusRegisters reads the given registers in a single request and stores the decoded values in values
+func readModbusRegisters(client sunspec.Reader, registers []ModbusRegister, values ModbusRegisterValues) error {
+	var max = maxBy(registers, func(a ModbusRegister, b ModbusRegister) int64 {
+		return int64(a.Address) - int64(b.Address)
+	})
+	var min = maxBy(registers, func(a ModbusRegister, b ModbusRegister) int64 {
+		return int64(b.Address) - int64(a.Address)
+	})
+
+	result, err := client.ReadRegisters(min.Address, max.Address+max.Size-min.Address, modbus.HOLDING_REGISTER)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range registers {
+		values[r.Name] = ModbusRegisterValue{r, decodeValue(min.Address, result, r)}
+	}
+	return nil
+}
+
 func decodeValue(start uint16, result []uint16, r ModbusRegister) interface{} {
 	slice := result[r.Address-start : r.Address-start+r.Size] // A register is 2 bytes
 	switch r.DataType {
@@ -247,10 +385,22 @@ func decodeValue(start uint16, result []uint16, r ModbusRegister) interface{} {
 		var value float32
 		binary.Read(buf, binary.LittleEndian, &value)
 		return value
-	case SUNSPEC_DID_INDEX:
-		return SUNSPEC_DID_MAP[slice[0]]
-	case INVERTER_STATUS_INDEX:
-		return INVERTER_STATUS_MAP[slice[0]]
+	case UINT32:
+		buf := new(bytes.Buffer)
+		for _, v := range slice {
+			binary.Write(buf, binary.LittleEndian, v)
+		}
+		var value uint32
+		binary.Read(buf, binary.LittleEndian, &value)
+		return value
+	case UINT64:
+		buf := new(bytes.Buffer)
+		for _, v := range slice {
+			binary.Write(buf, binary.LittleEndian, v)
+		}
+		var value uint64
+		binary.Read(buf, binary.LittleEndian, &value)
+		return value
 	}
 	log.Fatal("Unknown type")
 	return ""
@@ -278,18 +428,6 @@ func maxBy[T any](l []T, comparator func(T, T) int64) T {
 
 // https://www.solaredge.com/sites/default/files/sunspec-implementation-technical-note.pdf
 
-// Inverter Statuses
-const (
-	Ivs_I_STATUS_OFF           = 1
-	Ivs_I_STATUS_SLEEPING      = 2
-	Ivs_I_STATUS_STARTING      = 3
-	Ivs_I_STATUS_MPPT          = 4
-	Ivs_I_STATUS_THROTTLED     = 5
-	Ivs_I_STATUS_SHUTTING_DOWN = 6
-	Ivs_I_STATUS_FAULT         = 7
-	Ivs_I_STATUS_STANDBY       = 8
-)
-
 type DataType int64
 
 const (
@@ -299,8 +437,9 @@ const (
 	ACC32
 	STRING
 	FLOAT32
-	SUNSPEC_DID_INDEX
-	INVERTER_STATUS_INDEX
+	// UINT32 and UINT64 are, like FLOAT32, stored with the least significant register first
+	UINT32
+	UINT64
 )
 
 type ModbusRegister struct {
@@ -313,95 +452,9 @@ type ModbusRegister struct {
 	Batch       int
 }
 
-var SUNSPEC_DID_MAP = map[uint16]string{
-	101: "Single Phase Inverter",
-	102: "Split Phase Inverter",
-	103: "Three Phase Inverter",
-	201: "Single Phase Meter",
-	202: "Split Phase Meter",
-	203: "Wye 3P1N Three Phase Meter",
-	204: "Delta 3P Three Phase Meter",
-	802: "Battery",
-	803: "Lithium Ion Bank Battery",
-	804: "Lithium Ion String Battery",
-	805: "Lithium Ion Module Battery",
-	806: "Flow Battery",
-	807: "Flow String Battery",
-	808: "Flow Module Battery",
-	809: "Flow Stack Battery",
-}
-
-var INVERTER_STATUS_MAP = []string{
-	"Undefined",
-	"Off",
-	"Sleeping",
-	"Grid Monitoring",
-	"Producing",
-	"Producing (Throttled)",
-	"Shutting Down",
-	"Fault",
-	"Standby",
-}
-
+// Registers are the SolarEdge power control registers
 var Registers = []ModbusRegister{
-	{"c_id", 0x9c40, 2, STRING, "SunSpec ID", "", 1},
-	{"c_did", 0x9c42, 1, UINT16, "SunSpec DID", "", 1},
-	{"c_length", 0x9c43, 1, UINT16, "SunSpec Length", "16Bit Words", 1},
-	{"c_manufacturer", 0x9c44, 16, STRING, "Manufacturer", "", 1},
-	{"c_model", 0x9c54, 16, STRING, "Model", "", 1},
-	{"c_version", 0x9c6c, 8, STRING, "Version", "", 1},
-	{"c_serialnumber", 0x9c74, 16, STRING, "Serial", "", 1},
-	{"c_deviceaddress", 0x9c84, 1, UINT16, "Modbus ID", "", 1},
-
-	{"c_sunspec_did", 0x9c85, 1, SUNSPEC_DID_INDEX, "SunSpec DID", "", 2},
-	{"c_sunspec_length", 0x9c86, 1, UINT16, "Length", "16Bit Words", 2},
-
-	{"current", 0x9c87, 1, UINT16, "Current", "A", 2},
-	{"l1_current", 0x9c88, 1, UINT16, "L1 Current", "A", 2},
-	{"l2_current", 0x9c89, 1, UINT16, "L2 Current", "A", 2},
-	{"l3_current", 0x9c8a, 1, UINT16, "L3 Current", "A", 2},
-	{"current_scale", 0x9c8b, 1, SCALE, "Current Scale Factor", "", 2},
-
-	{"l1_voltage", 0x9c8c, 1, UINT16, "L1 Voltage", "V", 2},
-	{"l2_voltage", 0x9c8d, 1, UINT16, "L2 Voltage", "V", 2},
-	{"l3_voltage", 0x9c8e, 1, UINT16, "L3 Voltage", "V", 2},
-	{"l1n_voltage", 0x9c8f, 1, UINT16, "L1-N Voltage", "V", 2},
-	{"l2n_voltage", 0x9c90, 1, UINT16, "L2-N Voltage", "V", 2},
-	{"l3n_voltage", 0x9c91, 1, UINT16, "L3-N Voltage", "V", 2},
-	{"voltage_scale", 0x9c92, 1, SCALE, "Voltage Scale Factor", "", 2},
-
-	{"power_ac", 0x9c93, 1, INT16, "Power", "W", 2},
-	{"power_ac_scale", 0x9c94, 1, SCALE, "Power Scale Factor", "", 2},
-
-	{"frequency", 0x9c95, 1, UINT16, "Frequency", "Hz", 2},
-	{"frequency_scale", 0x9c96, 1, SCALE, "Frequency Scale Factor", "", 2},
-
-	{"power_apparent", 0x9c97, 1, INT16, "Power (Apparent)", "VA", 2},
-	{"power_apparent_scale", 0x9c98, 1, SCALE, "Power (Apparent) Scale Factor", "", 2},
-	{"power_reactive", 0x9c99, 1, INT16, "Power (Reactive)", "VAr", 2},
-	{"power_reactive_scale", 0x9c9a, 1, SCALE, "Power (Reactive) Scale Factor", "", 2},
-	{"power_factor", 0x9c9b, 1, INT16, "Power Factor", "%", 2},
-	{"power_factor_scale", 0x9c9c, 1, SCALE, "Power Factor Scale Factor", "", 2},
-
-	{"energy_total", 0x9c9d, 2, ACC32, "Total Energy", "Wh", 2},
-	{"energy_total_scale", 0x9c9f, 1, SCALE, "Total Energy Scale Factor", "", 2},
-
-	{"current_dc", 0x9ca0, 1, UINT16, "DC Current", "A", 2},
-	{"current_dc_scale", 0x9ca1, 1, SCALE, "DC Current Scale Factor", "", 2},
-
-	{"voltage_dc", 0x9ca2, 1, UINT16, "DC Voltage", "V", 2},
-	{"voltage_dc_scale", 0x9ca3, 1, SCALE, "DC Voltage Scale Factor", "", 2},
-
-	{"power_dc", 0x9ca4, 1, INT16, "DC Power", "W", 2},
-	{"power_dc_scale", 0x9ca5, 1, SCALE, "DC Power Scale Factor", "", 2},
-
-	{"temperature", 0x9ca7, 1, INT16, "Temperature", "°C", 2},
-	{"temperature_scale", 0x9caa, 1, SCALE, "Temperature Scale Factor", "", 2},
-
-	{"status", 0x9cab, 1, INVERTER_STATUS_INDEX, "Status", "", 2},
-	{"vendor_status", 0x9cac, 1, UINT16, "Vendor Status", "", 2},
-
-	{"rrcr_state", 0xf000, 1, UINT16, "RRCR State", "", 3},
-	{"active_power_limit", 0xf001, 1, UINT16, "Active Power Limit", "%", 3},
-	{"cosphi", 0xf002, 2, FLOAT32, "CosPhi", "", 3},
+	{"rrcr_state", 0xf000, 1, UINT16, "RRCR State", "", 1},
+	{"active_power_limit", 0xf001, 1, UINT16, "Active Power Limit", "%", 1},
+	{"cosphi", 0xf002, 2, FLOAT32, "CosPhi", "", 1},
 }
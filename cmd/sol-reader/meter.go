@@ -0,0 +1,87 @@
+package main
+
+import (
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/gmulders/smart-meter-readings/bus"
+	"github.com/gmulders/smart-meter-readings/sunspec"
+	log "github.com/sirupsen/logrus"
+)
+
+// readEnergyMeter reads the first meter (SunSpec model 201 - 204), when one is attached to the inverter, and sends the
+// readout to ch
+func readEnergyMeter(d *Device, device *sunspecDevice, timestamp time.Time, ch chan smr.EnergyMeterReadout) {
+	h, ok := device.Find(201, 202, 203, 204)
+	if !ok {
+		return
+	}
+	readout, err := ReadEnergyMeterMeasurement(d.unit, h)
+	if err != nil {
+		log.Errorf("%s: could not read the energy meter: %v", d.Name, err)
+		return
+	}
+	readout.Timestamp = timestamp
+	readout.Device = d.source("meter")
+	readout.Serial = device.meterSerial
+
+	ch <- *readout
+}
+
+func ReadEnergyMeterMeasurement(client *bus.Unit, h sunspec.Header) (*smr.EnergyMeterReadout, error) {
+	block, err := sunspec.Read(client, h)
+	if err != nil {
+		return nil, err
+	}
+	return decodeEnergyMeter(block), nil
+}
+
+// decodeEnergyMeter returns the readout of a meter model, the fields of the phases that the meter does not have are 0
+func decodeEnergyMeter(block *sunspec.Block) *smr.EnergyMeterReadout {
+	// The registers of the phases that the meter does not have are not implemented, a delta meter has no line to
+	// neutral voltages
+	measurement := &smr.EnergyMeterReadout{}
+	measurement.Phases = meterPhases(block.Header.ID)
+	measurement.Current = block.Int64("A", 3)
+	measurement.L1Current = block.Int64("AphA", 3)
+	measurement.VoltageLN = block.Int64("PhV", 3)
+	measurement.L1NVoltage = block.Int64("PhVphA", 3)
+	measurement.VoltageLL = block.Int64("PPV", 3)
+	measurement.Frequency = block.Int64("Hz", 3)
+	measurement.Power = block.Int64("W", 0)
+	measurement.L1Power = block.Int64("WphA", 0)
+	measurement.Exported = block.Int64("TotWhExp", 0)
+	measurement.L1Exported = block.Int64("TotWhExpPhA", 0)
+	measurement.Imported = block.Int64("TotWhImp", 0)
+	measurement.L1Imported = block.Int64("TotWhImpPhA", 0)
+
+	if measurement.Phases >= 2 {
+		measurement.L1L2Voltage = block.Int64("PPVphAB", 3)
+		measurement.L2Current = block.Int64("AphB", 3)
+		measurement.L2NVoltage = block.Int64("PhVphB", 3)
+		measurement.L2Power = block.Int64("WphB", 0)
+		measurement.L2Exported = block.Int64("TotWhExpPhB", 0)
+		measurement.L2Imported = block.Int64("TotWhImpPhB", 0)
+	}
+	if measurement.Phases >= 3 {
+		measurement.L2L3Voltage = block.Int64("PPVphBC", 3)
+		measurement.L3L1Voltage = block.Int64("PPVphCA", 3)
+		measurement.L3Current = block.Int64("AphC", 3)
+		measurement.L3NVoltage = block.Int64("PhVphC", 3)
+		measurement.L3Power = block.Int64("WphC", 0)
+		measurement.L3Exported = block.Int64("TotWhExpPhC", 0)
+		measurement.L3Imported = block.Int64("TotWhImpPhC", 0)
+	}
+	return measurement
+}
+
+// meterPhases returns the number of phases of the meter with the given SunSpec model
+func meterPhases(model uint16) int8 {
+	switch model {
+	case 202:
+		return 2
+	case 203, 204:
+		return 3
+	}
+	return 1
+}
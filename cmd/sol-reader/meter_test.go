@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/gmulders/smart-meter-readings/sunspec"
+)
+
+// meterBlock returns a meter model with the same values for every model. The values of a point that is not set are
+// "not implemented".
+func meterBlock(t *testing.T, id uint16) *sunspec.Block {
+	model := sunspec.Models[id]
+	registers := make([]uint16, model.Length)
+	for _, p := range model.Points {
+		switch p.Type {
+		case sunspec.Int16, sunspec.ScaleFactor:
+			registers[p.Offset] = 0x8000
+		}
+	}
+	set := func(name string, values ...uint16) {
+		for _, p := range model.Points {
+			if p.Name == name {
+				copy(registers[p.Offset:p.Offset+p.Size], values)
+				return
+			}
+		}
+		t.Fatalf("model %d has no point %s", id, name)
+	}
+
+	// 0.1 A, 0.1 V, 0.01 Hz, 1 W and 10 Wh
+	set("A_SF", 0xffff)
+	set("V_SF", 0xffff)
+	set("Hz_SF", 0xfffe)
+	set("W_SF", 0)
+	set("TotWh_SF", 1)
+	set("A", 151)
+	set("AphA", 51)
+	set("AphB", 50)
+	set("AphC", 50)
+	set("PhV", 2301)
+	set("PhVphA", 2302)
+	set("PhVphB", 2303)
+	set("PhVphC", 2304)
+	set("PPV", 3985)
+	set("PPVphAB", 3986)
+	set("PPVphBC", 3987)
+	set("PPVphCA", 3988)
+	set("Hz", 5001)
+	set("W", 0xfc18) // -1000 W
+	set("WphA", 0xfeca)
+	set("WphB", 0xfecb)
+	set("WphC", 0xfecc)
+	set("TotWhExp", 0x0001, 0x0000) // 65536 * 10 Wh
+	set("TotWhExpPhA", 0, 1000)
+	set("TotWhExpPhB", 0, 2000)
+	set("TotWhExpPhC", 0, 3000)
+	set("TotWhImp", 0, 600)
+	set("TotWhImpPhA", 0, 100)
+	set("TotWhImpPhB", 0, 200)
+	set("TotWhImpPhC", 0, 300)
+	return sunspec.Decode(model, sunspec.Header{ID: id, Length: model.Length}, registers)
+}
+
+func TestDecodeEnergyMeter(t *testing.T) {
+	single := smr.EnergyMeterReadout{
+		Phases:     1,
+		Current:    15100,
+		L1Current:  5100,
+		VoltageLN:  230100,
+		L1NVoltage: 230200,
+		VoltageLL:  398500,
+		Frequency:  50010,
+		Power:      -1000,
+		L1Power:    -310,
+		Exported:   655360,
+		L1Exported: 10000,
+		Imported:   6000,
+		L1Imported: 1000,
+	}
+	split := single
+	split.Phases = 2
+	split.L1L2Voltage = 398600
+	split.L2Current = 5000
+	split.L2NVoltage = 230300
+	split.L2Power = -309
+	split.L2Exported = 20000
+	split.L2Imported = 2000
+	three := split
+	three.Phases = 3
+	three.L2L3Voltage = 398700
+	three.L3L1Voltage = 398800
+	three.L3Current = 5000
+	three.L3NVoltage = 230400
+	three.L3Power = -308
+	three.L3Exported = 30000
+	three.L3Imported = 3000
+
+	for id, expected := range map[uint16]smr.EnergyMeterReadout{201: single, 202: split, 203: three, 204: three} {
+		if readout := decodeEnergyMeter(meterBlock(t, id)); *readout != expected {
+			t.Errorf("model %d: got %+v, expected %+v", id, *readout, expected)
+		}
+	}
+}
+
+func TestDecodeEnergyMeterNotImplemented(t *testing.T) {
+	// A delta meter has no neutral, the line to neutral voltages are not implemented
+	model := sunspec.Models[204]
+	registers := make([]uint16, model.Length)
+	for i := range registers {
+		registers[i] = 0x8000
+	}
+	readout := decodeEnergyMeter(sunspec.Decode(model, sunspec.Header{ID: 204, Length: model.Length}, registers))
+	if *readout != (smr.EnergyMeterReadout{Phases: 3}) {
+		t.Errorf("expected an empty readout, got %+v", *readout)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/gmulders/smart-meter-readings/bus"
+	"github.com/gmulders/smart-meter-readings/registermap"
+	retry "github.com/sethvargo/go-retry"
+	log "github.com/sirupsen/logrus"
+)
+
+// readRegisterMapStream polls the device with its register map and sends the readouts to ch
+func readRegisterMapStream(d *Device, ch chan smr.ModbusReadout) {
+	strategy := retry.WithMaxRetries(8, retry.NewFibonacci(100*time.Millisecond))
+
+	for {
+		retry.Do(context.Background(), strategy, func(ctx context.Context) error {
+			readout, err := ReadModbusReadout(d.unit, d.registerMap)
+			if err != nil {
+				log.Errorf("%s: could not read the registers: %v", d.Name, err)
+				return retry.RetryableError(err)
+			}
+			readout.Tags["source"] = d.Name
+
+			ch <- *readout
+			return nil
+		})
+
+		time.Sleep(d.interval)
+	}
+}
+
+// ReadModbusReadout reads the registers of the map, the numbers become the fields of the readout and the strings its
+// tags
+func ReadModbusReadout(client *bus.Unit, m *registermap.Map) (*smr.ModbusReadout, error) {
+	values, err := registermap.Read(client, m)
+	if err != nil {
+		return nil, err
+	}
+
+	readout := &smr.ModbusReadout{
+		Timestamp:   time.Now(),
+		Measurement: m.Measurement,
+		Tags:        map[string]string{"source": m.Name},
+	}
+	for _, v := range values {
+		if v.Register.Type == registermap.String {
+			// Influx does not accept empty tags
+			if v.Text != "" {
+				readout.Tags[v.Register.Name] = v.Text
+			}
+			continue
+		}
+		readout.Fields = append(readout.Fields, smr.ModbusField{Name: v.Register.Name, Value: v.Float})
+	}
+	return readout, nil
+}
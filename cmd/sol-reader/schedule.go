@@ -0,0 +1,44 @@
+package main
+
+import (
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+)
+
+// inverterSchedule decides when the inverter model is read. While the inverter is asleep the time between two reads is
+// doubled up to the night interval, as soon as it reports another status it is read at the interval again.
+type inverterSchedule struct {
+	interval      time.Duration
+	nightInterval time.Duration
+
+	// current is the time between the last two reads
+	current time.Duration
+	next    time.Time
+}
+
+// asleep reports whether the inverter does not produce, e.g. at night
+func asleep(status smr.InverterStatus) bool {
+	return status == smr.InverterStatusSleeping || status == smr.InverterStatusOff
+}
+
+// due returns whether the inverter must be read
+func (s *inverterSchedule) due(now time.Time) bool {
+	return !now.Before(s.next)
+}
+
+// read schedules the next read after the inverter reported the status at now
+func (s *inverterSchedule) read(now time.Time, status smr.InverterStatus) {
+	if !asleep(status) {
+		s.current = s.interval
+	} else {
+		s.current *= 2
+		if s.current > s.nightInterval {
+			s.current = s.nightInterval
+		}
+		if s.current < s.interval {
+			s.current = s.interval
+		}
+	}
+	s.next = now.Add(s.current)
+}
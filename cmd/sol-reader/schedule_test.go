@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+)
+
+func TestInverterSchedule(t *testing.T) {
+	s := &inverterSchedule{interval: 10 * time.Second, nightInterval: time.Minute}
+	now := time.Date(2023, 6, 1, 21, 0, 0, 0, time.UTC)
+	if !s.due(now) {
+		t.Fatal("expected the first read to be due")
+	}
+
+	for _, step := range []struct {
+		status smr.InverterStatus
+		next   time.Duration
+	}{
+		{smr.InverterStatusProducing, 10 * time.Second},
+		// Asleep the interval is doubled up to the night interval
+		{smr.InverterStatusSleeping, 20 * time.Second},
+		{smr.InverterStatusSleeping, 40 * time.Second},
+		{smr.InverterStatusOff, time.Minute},
+		{smr.InverterStatusSleeping, time.Minute},
+		// Awake it is read at the interval at once
+		{smr.InverterStatusStarting, 10 * time.Second},
+		{smr.InverterStatusSleeping, 20 * time.Second},
+		{smr.InverterStatusFault, 10 * time.Second},
+	} {
+		s.read(now, step.status)
+		if s.due(now.Add(step.next - time.Second)) {
+			t.Errorf("%s: due before %s", step.status, step.next)
+		}
+		if !s.due(now.Add(step.next)) {
+			t.Errorf("%s: not due after %s", step.status, step.next)
+		}
+		now = now.Add(step.next)
+	}
+}
+
+func TestInverterScheduleShortNightInterval(t *testing.T) {
+	s := &inverterSchedule{interval: 10 * time.Second, nightInterval: 5 * time.Second}
+	now := time.Date(2023, 6, 1, 21, 0, 0, 0, time.UTC)
+	s.read(now, smr.InverterStatusSleeping)
+	s.read(now, smr.InverterStatusSleeping)
+	if s.due(now.Add(9*time.Second)) || !s.due(now.Add(10*time.Second)) {
+		t.Error("expected the interval when the night interval is shorter")
+	}
+}
+
+func TestAsleep(t *testing.T) {
+	for status, expected := range map[smr.InverterStatus]bool{
+		smr.InverterStatusUndefined: false,
+		smr.InverterStatusOff:       true,
+		smr.InverterStatusSleeping:  true,
+		smr.InverterStatusStarting:  false,
+		smr.InverterStatusProducing: false,
+		smr.InverterStatusThrottled: false,
+		smr.InverterStatusFault:     false,
+		smr.InverterStatusStandby:   false,
+	} {
+		if asleep(status) != expected {
+			t.Errorf("%s: asleep is %t", status, !expected)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package meterstanden
+
+// deviceTags returns the tags of a readout of a device: the name of the device as its source (defaultSource when the
+// device has no name) and the serial number of the device when it is known
+func deviceTags(device string, serial string, defaultSource string) map[string]string {
+	tags := map[string]string{"source": defaultSource}
+	if device != "" {
+		tags["source"] = device
+	}
+	// Influx does not accept empty tags
+	if serial != "" {
+		tags["serial"] = serial
+	}
+	return tags
+}
+
+// deviceStream returns the name of the stream the readouts of a device are written to, a device without a name writes
+// to the stream with the base name
+func deviceStream(base string, device string) string {
+	if device == "" {
+		return base
+	}
+	return base + "-" + device
+}
@@ -0,0 +1,242 @@
+package dsmr
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+)
+
+func winter(year, month, day, hour, min, sec int) time.Time {
+	return time.Date(year, time.Month(month), day, hour, min, sec, 0, time.FixedZone("CET", 3600)).UTC()
+}
+
+func summer(year, month, day, hour, min, sec int) time.Time {
+	return time.Date(year, time.Month(month), day, hour, min, sec, 0, time.FixedZone("CEST", 7200)).UTC()
+}
+
+// corpus holds the telegrams in testdata/telegrams with the telegram they are expected to decode to
+var corpus = []struct {
+	file     string
+	expected smr.Telegram
+}{
+	{"kaifa-ma105-dsmr42.txt", smr.Telegram{
+		Timestamp:              winter(2016, 11, 13, 20, 57, 57),
+		ConsumedTariff1:        1581123,
+		ConsumedTariff2:        1435706,
+		CurrentTariff:          2,
+		PowerConsumption:       2027,
+		PowerConsumptionPhase1: 2027,
+		CurrentPhase1:          9000,
+		Version:                42,
+		EquipmentIdentifier:    "E0025001234567816",
+		PowerFailures:          15,
+		LongPowerFailures:      7,
+		PowerFailureLog: []smr.PowerFailureEvent{
+			{End: winter(2000, 1, 4, 18, 3, 20), Duration: 237126},
+			{End: winter(2000, 1, 1, 0, 0, 1), Duration: 2147583646},
+			{End: winter(2000, 1, 2, 0, 0, 3), Duration: 2317482647},
+		},
+		MBus: []smr.MBusReading{
+			{Timestamp: winter(2016, 11, 13, 20, 0, 0), Channel: 1, DeviceType: smr.MBusDeviceTypeGas,
+				EquipmentIdentifier: "G0019340221198415", Value: 981443},
+		},
+	}},
+	{"landisgyr-e350-dsmr42.txt", smr.Telegram{
+		Timestamp:              winter(2017, 1, 24, 21, 31, 28),
+		ConsumedTariff1:        4001128,
+		ConsumedTariff2:        3124642,
+		CurrentTariff:          2,
+		PowerConsumption:       618,
+		PowerConsumptionPhase1: 235,
+		PowerConsumptionPhase2: 187,
+		PowerConsumptionPhase3: 196,
+		CurrentPhase1:          1000,
+		CurrentPhase2:          1000,
+		CurrentPhase3:          1000,
+		Version:                42,
+		EquipmentIdentifier:    "E0031003000012917",
+		PowerFailures:          5,
+		LongPowerFailures:      3,
+		PowerFailureLog: []smr.PowerFailureEvent{
+			{End: summer(2015, 9, 24, 14, 35, 42), Duration: 295},
+			{End: winter(2016, 12, 18, 10, 13, 55), Duration: 4153},
+		},
+		VoltageSagsPhase1: 2,
+		VoltageSagsPhase2: 2,
+		VoltageSagsPhase3: 2,
+		MBus: []smr.MBusReading{
+			{Timestamp: winter(2017, 1, 24, 21, 0, 0), Channel: 1, DeviceType: smr.MBusDeviceTypeGas,
+				EquipmentIdentifier: "G0028001234567016", Value: 2352917},
+		},
+	}},
+	{"iskra-am550-dsmr50.txt", smr.Telegram{
+		Timestamp:              summer(2019, 6, 17, 15, 14, 12),
+		ConsumedTariff1:        2074842,
+		ConsumedTariff2:        881383,
+		DeliveredTariff1:       10981,
+		DeliveredTariff2:       28031,
+		CurrentTariff:          2,
+		PowerDelivery:          1562,
+		PowerConsumptionPhase2: 112,
+		PowerConsumptionPhase3: 198,
+		PowerDeliveryPhase1:    1872,
+		VoltagePhase1:          236400,
+		VoltagePhase2:          235800,
+		VoltagePhase3:          236100,
+		CurrentPhase1:          8000,
+		CurrentPhase3:          1000,
+		Version:                50,
+		EquipmentIdentifier:    "E0044007312345619",
+		PowerFailures:          6,
+		LongPowerFailures:      3,
+		PowerFailureLog: []smr.PowerFailureEvent{
+			{End: winter(2000, 1, 1, 0, 0, 1), Duration: 2147483647},
+		},
+		VoltageSagsPhase1: 3,
+		VoltageSagsPhase2: 2,
+		VoltageSagsPhase3: 2,
+		TextMessage:       "Onderhoud op 24 juni",
+		MBus: []smr.MBusReading{
+			{Timestamp: summer(2019, 6, 17, 15, 10, 0), Channel: 1, DeviceType: smr.MBusDeviceTypeGas,
+				EquipmentIdentifier: "G0059003123456719", Value: 1372564},
+		},
+	}},
+	{"sagemcom-t210d-dsmr50.txt", smr.Telegram{
+		Timestamp:           winter(2020, 3, 19, 10, 39, 22),
+		ConsumedTariff1:     423170,
+		ConsumedTariff2:     391865,
+		DeliveredTariff1:    120436,
+		DeliveredTariff2:    287121,
+		CurrentTariff:       2,
+		PowerDelivery:       840,
+		PowerDeliveryPhase1: 840,
+		VoltagePhase1:       237000,
+		CurrentPhase1:       3000,
+		Version:             50,
+		EquipmentIdentifier: "E0052006123456720",
+		PowerFailures:       8,
+		LongPowerFailures:   2,
+		PowerFailureLog:     []smr.PowerFailureEvent{},
+		VoltageSagsPhase1:   1,
+		MBus: []smr.MBusReading{
+			{Timestamp: winter(2020, 3, 19, 10, 35, 0), Channel: 1, DeviceType: smr.MBusDeviceTypeGas,
+				EquipmentIdentifier: "G0073004987654320", Value: 347920},
+		},
+	}},
+	{"kamstrup-382-dsmr42.txt", smr.Telegram{
+		Timestamp:              winter(2017, 1, 8, 16, 11, 7),
+		ConsumedTariff1:        1264808,
+		ConsumedTariff2:        1032711,
+		CurrentTariff:          1,
+		PowerConsumption:       478,
+		PowerConsumptionPhase1: 287,
+		PowerConsumptionPhase2: 24,
+		PowerConsumptionPhase3: 167,
+		CurrentPhase1:          1000,
+		CurrentPhase3:          1000,
+		Version:                42,
+		EquipmentIdentifier:    "ZABF001587315111",
+		PowerFailures:          1,
+		PowerFailureLog:        []smr.PowerFailureEvent{},
+		MBus: []smr.MBusReading{
+			{Timestamp: winter(2017, 1, 8, 16, 0, 0), Channel: 1, DeviceType: smr.MBusDeviceTypeGas,
+				EquipmentIdentifier: "G0062002134512316", Value: 823571},
+		},
+	}},
+	{"sagemcom-s211-emucs.txt", smr.Telegram{
+		Timestamp:              winter(2023, 3, 15, 14, 35, 17),
+		ConsumedTariff1:        753921,
+		ConsumedTariff2:        1204618,
+		DeliveredTariff1:       431076,
+		DeliveredTariff2:       166201,
+		CurrentTariff:          1,
+		PowerConsumption:       2109,
+		PowerConsumptionPhase1: 1321,
+		PowerConsumptionPhase2: 512,
+		PowerConsumptionPhase3: 276,
+		VoltagePhase1:          231200,
+		VoltagePhase2:          232800,
+		VoltagePhase3:          230900,
+		CurrentPhase1:          5940,
+		CurrentPhase2:          2310,
+		CurrentPhase3:          1270,
+		EquipmentIdentifier:    "1SAG1031663457",
+		MBus: []smr.MBusReading{
+			{Timestamp: winter(2023, 3, 15, 14, 35, 0), Channel: 1, DeviceType: smr.MBusDeviceTypeGas,
+				EquipmentIdentifier: "7FLO2119033733", Value: 1672398},
+		},
+		CapacityTariff: &smr.CapacityTariff{
+			Version:                50217,
+			CurrentAverageDemand:   1986,
+			MaximumDemand:          4582,
+			MaximumDemandTimestamp: winter(2023, 3, 2, 19, 0, 0),
+			MaximumDemandHistory: []smr.DemandPeak{
+				{PeriodEnd: winter(2023, 1, 1, 0, 0, 0), Timestamp: winter(2022, 12, 19, 18, 15, 0), Value: 5120},
+				{PeriodEnd: winter(2023, 2, 1, 0, 0, 0), Timestamp: winter(2023, 1, 17, 7, 30, 0), Value: 4876},
+				{PeriodEnd: winter(2023, 3, 1, 0, 0, 0), Timestamp: winter(2023, 2, 14, 18, 30, 0), Value: 4215},
+			},
+		},
+	}},
+}
+
+func TestReadTelegramStreamCorpus(t *testing.T) {
+	for _, c := range corpus {
+		t.Run(c.file, func(t *testing.T) {
+			content, err := os.ReadFile("testdata/telegrams/" + c.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			telegrams := readAll(t, string(content), Config{})
+			if len(telegrams) != 1 {
+				t.Fatalf("expected 1 telegram, got %d", len(telegrams))
+			}
+			if !reflect.DeepEqual(telegrams[0], c.expected) {
+				t.Errorf("unexpected telegram\n got: %+v\nwant: %+v", telegrams[0], c.expected)
+			}
+		})
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected smr.Telegram
+		err      bool
+	}{
+		{line: "1-0:1.8.1(001234.567*kWh)\r\n", expected: smr.Telegram{ConsumedTariff1: 1234567}},
+		{line: "1-0:1.7.0(01.2*kW)", expected: smr.Telegram{PowerConsumption: 1200}},
+		{line: "1-0:31.7.0(005.94*A)", expected: smr.Telegram{CurrentPhase1: 5940}},
+		{line: "0-0:96.14.0(0002)", expected: smr.Telegram{CurrentTariff: 2}},
+		{line: "0-0:1.0.0(101209113020W)", expected: smr.Telegram{Timestamp: winter(2010, 12, 9, 11, 30, 20)}},
+		{line: "0-0:96.13.0()", expected: smr.Telegram{}},
+		{line: "1-0:1.8.1(001234.5678*kWh)", err: true},
+		{line: "1-0:1.8.1(001234.567*Wh)", err: true},
+		{line: "1-0:1.8.1(001234.567*kWh", err: true},
+		{line: "0-0:1.0.0(1012091130)", err: true},
+		{line: "0-0:96.13.0(4)", err: true},
+		{line: "1-0:99.97.0(2)(0-0:96.7.19)(101208152415W)", err: true},
+		{line: "1-0:99.97.0", err: true},
+		{line: "(001234.567*kWh)", err: true},
+		{line: "0-0:96.99.9(1)", err: true},
+	}
+
+	for _, test := range tests {
+		telegram := smr.Telegram{}
+		err := ParseLine(&telegram, test.line, nil)
+		if test.err {
+			if err == nil {
+				t.Errorf("expected an error for '%s'", test.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for '%s': %v", test.line, err)
+		} else if !reflect.DeepEqual(telegram, test.expected) {
+			t.Errorf("unexpected telegram for '%s': %+v", test.line, telegram)
+		}
+	}
+}
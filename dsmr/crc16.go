@@ -1,4 +1,4 @@
-package main
+package dsmr
 
 var crcTable = makeTable()
 
@@ -20,8 +20,8 @@ func makeTable() []uint16 {
 	return array[:]
 }
 
-// crc16 calculates the crc16 of the input bytes and updates the given crc16 with this value.
-func crc16(crc uint16, buf []byte) uint16 {
+// CRC16 calculates the crc16 of the input bytes and updates the given crc16 with this value.
+func CRC16(crc uint16, buf []byte) uint16 {
 	for _, v := range buf {
 		crc = crcTable[byte(crc)^v] ^ (crc >> 8)
 	}
@@ -0,0 +1,179 @@
+package dsmr
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// generalGloCipheringTag starts a DLMS general-glo-ciphering frame
+	generalGloCipheringTag = 0xdb
+	// securityControlByte indicates that the frame is authenticated and encrypted
+	securityControlByte = 0x30
+	systemTitleLength   = 8
+	frameCounterLength  = 4
+	gcmTagLength        = 12
+	// maxFrameLength is the maximum length of the encrypted part of a frame that is accepted
+	maxFrameLength = 8192
+)
+
+// SmartyAuthenticationKey is the authentication key that is used by the Luxembourg Smarty meters
+var SmartyAuthenticationKey = []byte{
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff,
+}
+
+// DecryptingReader reads encrypted DLMS frames (AES-128-GCM) from the underlying reader and returns the decrypted
+// telegrams. Frames that can not be decrypted, e.g. because their authentication tag does not match, are skipped.
+type DecryptingReader struct {
+	reader  *bufio.Reader
+	aead    cipher.AEAD
+	authKey []byte
+
+	// The part of the last decrypted frame that was not read yet
+	buffer []byte
+}
+
+// NewDecryptingReader creates a reader that decrypts the frames read from r with the given (per meter) key. The
+// authentication key is used as additional authenticated data.
+func NewDecryptingReader(r io.Reader, key []byte, authKey []byte) (*DecryptingReader, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCMWithTagSize(block, gcmTagLength)
+	if err != nil {
+		return nil, err
+	}
+	return &DecryptingReader{
+		reader:  bufio.NewReader(r),
+		aead:    aead,
+		authKey: authKey,
+	}, nil
+}
+
+func (d *DecryptingReader) Read(p []byte) (int, error) {
+	for len(d.buffer) == 0 {
+		plaintext, err := d.readFrame()
+		if errors.Is(err, errInvalidFrame) {
+			log.Error(err)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		d.buffer = plaintext
+	}
+
+	n := copy(p, d.buffer)
+	d.buffer = d.buffer[n:]
+	return n, nil
+}
+
+var errInvalidFrame = errors.New("invalid DLMS frame")
+
+// readFrame reads the next frame and decrypts it. Bytes before the start of a frame are skipped.
+func (d *DecryptingReader) readFrame() ([]byte, error) {
+	if _, err := d.reader.ReadBytes(generalGloCipheringTag); err != nil {
+		return nil, err
+	}
+
+	// Only consume the length of the system title when it is valid, so that a frame that starts right after a stray
+	// tag byte is not missed
+	titleLength, err := d.reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if titleLength[0] != systemTitleLength {
+		return nil, fmt.Errorf("%w: unexpected system title length %d", errInvalidFrame, titleLength[0])
+	}
+	d.reader.Discard(1)
+
+	systemTitle := make([]byte, systemTitleLength)
+	if _, err := io.ReadFull(d.reader, systemTitle); err != nil {
+		return nil, err
+	}
+
+	length, err := d.readLength()
+	if err != nil {
+		return nil, err
+	}
+	if length < 1+frameCounterLength+gcmTagLength || length > maxFrameLength {
+		return nil, fmt.Errorf("%w: unexpected length %d", errInvalidFrame, length)
+	}
+
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(d.reader, frame); err != nil {
+		return nil, err
+	}
+	if frame[0] != securityControlByte {
+		return nil, fmt.Errorf("%w: unsupported security control byte 0x%02x", errInvalidFrame, frame[0])
+	}
+
+	// The initialisation vector consists of the system title followed by the frame counter
+	iv := append(systemTitle, frame[1:1+frameCounterLength]...)
+	additionalData := append([]byte{securityControlByte}, d.authKey...)
+
+	plaintext, err := d.aead.Open(nil, iv, frame[1+frameCounterLength:], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: frame %d could not be decrypted: %v", errInvalidFrame,
+			binary.BigEndian.Uint32(frame[1:1+frameCounterLength]), err)
+	}
+	return plaintext, nil
+}
+
+// readLength reads a BER encoded length
+func (d *DecryptingReader) readLength() (int, error) {
+	first, err := d.reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if first < 0x80 {
+		return int(first), nil
+	}
+
+	count := int(first & 0x7f)
+	if count == 0 || count > 2 {
+		return 0, fmt.Errorf("%w: unsupported length encoding 0x%02x", errInvalidFrame, first)
+	}
+	length := 0
+	for i := 0; i < count; i++ {
+		b, err := d.reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	return length, nil
+}
+
+// ContainsEncryptedTelegram returns a probe like ContainsTelegram for meters that encrypt their telegrams: it decrypts
+// the frames in the data with the keys before it looks for a telegram
+func ContainsEncryptedTelegram(key []byte, authKey []byte) (func(data []byte) bool, error) {
+	if _, err := NewDecryptingReader(nil, key, authKey); err != nil {
+		return nil, err
+	}
+	return func(data []byte) bool {
+		d, _ := NewDecryptingReader(bytes.NewReader(data), key, authKey)
+		var plaintext []byte
+		for {
+			// Frames that can not be decrypted are expected while probing, they are not logged
+			frame, err := d.readFrame()
+			if errors.Is(err, errInvalidFrame) {
+				continue
+			}
+			if err != nil {
+				break
+			}
+			plaintext = append(plaintext, frame...)
+		}
+		return ContainsTelegram(plaintext)
+	}, nil
+}
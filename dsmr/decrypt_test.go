@@ -0,0 +1,243 @@
+package dsmr
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+)
+
+var testKey = []byte{
+	0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+}
+
+// encryptFrame creates a general-glo-ciphering frame like the ones sent by the Smarty meters
+func encryptFrame(t *testing.T, key []byte, counter uint32, plaintext string) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCMWithTagSize(block, gcmTagLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	systemTitle := []byte("SAG\x11\x22\x33\x44\x55")
+	frameCounter := []byte{byte(counter >> 24), byte(counter >> 16), byte(counter >> 8), byte(counter)}
+	iv := append(append([]byte{}, systemTitle...), frameCounter...)
+	additionalData := append([]byte{securityControlByte}, SmartyAuthenticationKey...)
+	ciphertext := aead.Seal(nil, iv, []byte(plaintext), additionalData)
+
+	length := 1 + frameCounterLength + len(ciphertext)
+	frame := []byte{generalGloCipheringTag, systemTitleLength}
+	frame = append(frame, systemTitle...)
+	frame = append(frame, 0x82, byte(length>>8), byte(length))
+	frame = append(frame, securityControlByte)
+	frame = append(frame, frameCounter...)
+	return append(frame, ciphertext...)
+}
+
+func TestDecryptingReader(t *testing.T) {
+	var input bytes.Buffer
+	input.WriteString("\x00\xdb\x07garbage")
+	input.Write(encryptFrame(t, testKey, 1, "first frame\r\n"))
+	input.Write(encryptFrame(t, testKey, 2, "second frame\r\n"))
+
+	reader, err := NewDecryptingReader(&input, testKey, SmartyAuthenticationKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "first frame\r\nsecond frame\r\n" {
+		t.Errorf("unexpected output '%s'", output)
+	}
+}
+
+func TestDecryptingReaderSkipsFramesThatFailAuthentication(t *testing.T) {
+	tampered := encryptFrame(t, testKey, 1, "tampered frame\r\n")
+	tampered[len(tampered)-1] ^= 0x01
+
+	otherKey := append([]byte{}, testKey...)
+	otherKey[0] = 0xff
+
+	var input bytes.Buffer
+	input.Write(tampered)
+	input.Write(encryptFrame(t, otherKey, 2, "frame with other key\r\n"))
+	input.Write(encryptFrame(t, testKey, 3, "valid frame\r\n"))
+
+	reader, err := NewDecryptingReader(&input, testKey, SmartyAuthenticationKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "valid frame\r\n" {
+		t.Errorf("unexpected output '%s'", output)
+	}
+}
+
+func TestDecryptingReaderRejectsInvalidKey(t *testing.T) {
+	if _, err := NewDecryptingReader(&bytes.Buffer{}, []byte{0x01, 0x02}, SmartyAuthenticationKey); err == nil {
+		t.Error("expected an error for a key of invalid length")
+	}
+}
+
+// TestDecryptingReaderSyntheticFrame decrypts a stored frame and parses the resulting telegram. The frame is not
+// captured from a meter: it was encrypted with encryptFrame, i.e. with testKey and a made-up system title.
+func TestDecryptingReaderSyntheticFrame(t *testing.T) {
+	content, err := os.ReadFile("testdata/synthetic-smarty-frame.hex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep the pipe open, so that the stream blocks after the telegram instead of reading EOF
+	pipeReader, pipeWriter := io.Pipe()
+	defer pipeWriter.Close()
+	go pipeWriter.Write(frame)
+
+	reader, err := NewDecryptingReader(pipeReader, testKey, SmartyAuthenticationKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan smr.Telegram)
+	go ReadTelegramStream(bufio.NewReader(reader), ch, Config{})
+
+	select {
+	case telegram := <-ch:
+		if telegram.EquipmentIdentifier != "K8EG004046395507" {
+			t.Errorf("unexpected equipment identifier '%s'", telegram.EquipmentIdentifier)
+		}
+		if telegram.PowerConsumption != 187 {
+			t.Errorf("unexpected power consumption %d", telegram.PowerConsumption)
+		}
+		if telegram.VoltagePhase1 != 230100 {
+			t.Errorf("unexpected voltage %d", telegram.VoltagePhase1)
+		}
+		if telegram.PowerFailures != 3 {
+			t.Errorf("unexpected power failures %d", telegram.PowerFailures)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no telegram received")
+	}
+}
+
+// TestDecryptingReaderCapturedFrames decrypts the frames captured from Smarty meters in testdata/smarty with their
+// published keys, every capture must contain a telegram with a valid CRC
+func TestDecryptingReaderCapturedFrames(t *testing.T) {
+	captures, err := filepath.Glob("testdata/smarty/*.hex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(captures) == 0 {
+		t.Skip("no frames captured from a Smarty meter in testdata/smarty")
+	}
+
+	for _, capture := range captures {
+		t.Run(filepath.Base(capture), func(t *testing.T) {
+			frames := readHex(t, capture)
+			keys := strings.Fields(string(readFile(t, strings.TrimSuffix(capture, ".hex")+".key")))
+			if len(keys) == 0 {
+				t.Fatal("no key")
+			}
+			key, err := hex.DecodeString(keys[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			authKey := SmartyAuthenticationKey
+			if len(keys) > 1 {
+				if authKey, err = hex.DecodeString(keys[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			reader, err := NewDecryptingReader(bytes.NewReader(frames), key, authKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plaintext, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ContainsTelegram(plaintext) {
+				t.Errorf("no valid telegram in the decrypted frames '%s'", plaintext)
+			}
+		})
+	}
+}
+
+func readFile(t *testing.T, name string) []byte {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func readHex(t *testing.T, name string) []byte {
+	content, err := hex.DecodeString(strings.Join(strings.Fields(string(readFile(t, name))), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// TestContainsEncryptedTelegram probes the data of a port like a Smarty meter sends it, the telegram is only found
+// after decryption
+func TestContainsEncryptedTelegram(t *testing.T) {
+	telegram, err := os.ReadFile("testdata/telegrams/iskra-am550-dsmr50.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var encrypted bytes.Buffer
+	encrypted.WriteString("\x00\xdb\x07garbage")
+	encrypted.Write(encryptFrame(t, testKey, 1, string(telegram)))
+
+	probe, err := ContainsEncryptedTelegram(testKey, SmartyAuthenticationKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ContainsTelegram(encrypted.Bytes()) {
+		t.Error("expected no telegram in the encrypted data without decryption")
+	}
+	if !probe(encrypted.Bytes()) {
+		t.Error("expected a telegram in the encrypted data")
+	}
+	if probe(telegram) {
+		t.Error("expected no telegram in unencrypted data")
+	}
+
+	otherKey := append([]byte{}, testKey...)
+	otherKey[0] = 0xff
+	otherProbe, err := ContainsEncryptedTelegram(otherKey, SmartyAuthenticationKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherProbe(encrypted.Bytes()) {
+		t.Error("expected no telegram with another key")
+	}
+
+	if _, err := ContainsEncryptedTelegram([]byte{0x01, 0x02}, SmartyAuthenticationKey); err == nil {
+		t.Error("expected an error for a key of invalid length")
+	}
+}
@@ -0,0 +1,51 @@
+package dsmr
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"testing"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	log "github.com/sirupsen/logrus"
+)
+
+func FuzzReadTelegramStream(f *testing.F) {
+	for _, c := range corpus {
+		content, err := os.ReadFile("testdata/telegrams/" + c.file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(content)
+	}
+	f.Add([]byte("/KFM5KAIFA-METER\r\n\r\n!\r\n"))
+	f.Add([]byte("/KFM5KAIFA-METER\r\n\r\n!1\r\n"))
+	f.Add([]byte("/ISk5MT382-1000\r\n\r\n1-0:1.8.1\r\n(00001.001)\r\n!\r\n"))
+
+	log.SetLevel(log.FatalLevel)
+	f.Fuzz(func(t *testing.T, input []byte) {
+		ch := make(chan smr.Telegram)
+		go func() {
+			for range ch {
+			}
+		}()
+		defer close(ch)
+
+		if err := ReadTelegramStream(bufio.NewReader(bytes.NewReader(input)), ch, Config{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzParseLine(f *testing.F) {
+	f.Add("1-0:1.8.1(001234.567*kWh)\r\n")
+	f.Add("0-0:1.0.0(101209113020W)")
+	f.Add("1-0:99.97.0(2)(0-0:96.7.19)(101208152415W)(0000000240*s)(101208151004W)(0000000301*s)")
+	f.Add("0-0:98.1.0(1)(1-0:1.6.0)(1-0:1.6.0)(200501000000S)(200423192538S)(03.695*kW)")
+	f.Add("0-1:24.3.0(121030140000)(00)(60)(1)(0-1:24.2.1)(m3)(00000.012)")
+	f.Add("0-0:96.13.0(4F6E646572686F7564)")
+
+	f.Fuzz(func(t *testing.T, line string) {
+		ParseLine(&smr.Telegram{}, line, nil)
+	})
+}
@@ -0,0 +1,172 @@
+package dsmr
+
+import (
+	"fmt"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+)
+
+// ValueType determines how the values of a line are decoded
+type ValueType int
+
+const (
+	// Number is a decimal value with a unit, like `(001234.567*kWh)`
+	Number ValueType = iota
+	// Integer is a plain integer without a unit, like `(00004)`
+	Integer
+	// Timestamp is a timestamp with a DST indicator, like `(170108161107W)`
+	Timestamp
+	// OctetString is a string encoded as hexadecimal characters, like `(4B384547)`
+	OctetString
+	// Buffer is a list of timestamped values, like `(1)(0-0:96.7.19)(101208152415W)(0000000240*s)`. The unit and scale
+	// apply to the last value of every entry.
+	Buffer
+	// TimestampedNumber is a timestamp followed by a decimal value with a unit, like `(170108160000W)(00001.290*m3)`
+	TimestampedNumber
+	// CapturedNumber is the legacy (DSMR 2.2 and 3.0) notation of a captured value, where the unit and the value follow
+	// the timestamp, status, period and OBIS code, like `(090212160000)(00)(60)(1)(0-1:24.2.1)(m3)(00001.001)`. The
+	// value is sent on a separate line.
+	CapturedNumber
+)
+
+// Value holds the decoded value(s) of a line
+type Value struct {
+	Int  int64
+	Time time.Time
+	Text string
+	List []Value
+}
+
+// Register describes how the value of an OBIS code is decoded and in which field of the telegram it is stored
+type Register struct {
+	Type  ValueType
+	Unit  string // The unit the value must have (numbers only)
+	Scale int    // The value is multiplied by 10^Scale before it is stored (numbers only)
+	Set   func(t *smr.Telegram, v Value)
+}
+
+// Registers maps the OBIS codes to their register. Adding support for a register is done by adding it to this table.
+var Registers = map[string]Register{
+	"1-3:0.2.8":   {Integer, "", 0, func(t *smr.Telegram, v Value) { t.Version = int8(v.Int) }},
+	"0-0:1.0.0":   {Timestamp, "", 0, func(t *smr.Telegram, v Value) { t.Timestamp = v.Time }},
+	"0-0:96.1.1":  {OctetString, "", 0, func(t *smr.Telegram, v Value) { t.EquipmentIdentifier = v.Text }},
+	"1-0:1.8.1":   {Number, "kWh", 3, func(t *smr.Telegram, v Value) { t.ConsumedTariff1 = v.Int }},
+	"1-0:1.8.2":   {Number, "kWh", 3, func(t *smr.Telegram, v Value) { t.ConsumedTariff2 = v.Int }},
+	"1-0:2.8.1":   {Number, "kWh", 3, func(t *smr.Telegram, v Value) { t.DeliveredTariff1 = v.Int }},
+	"1-0:2.8.2":   {Number, "kWh", 3, func(t *smr.Telegram, v Value) { t.DeliveredTariff2 = v.Int }},
+	"0-0:96.14.0": {Integer, "", 0, func(t *smr.Telegram, v Value) { t.CurrentTariff = int8(v.Int) }},
+	"1-0:1.7.0":   {Number, "kW", 3, func(t *smr.Telegram, v Value) { t.PowerConsumption = v.Int }},
+	"1-0:2.7.0":   {Number, "kW", 3, func(t *smr.Telegram, v Value) { t.PowerDelivery = v.Int }},
+	"0-0:96.7.21": {Integer, "", 0, func(t *smr.Telegram, v Value) { t.PowerFailures = v.Int }},
+	"0-0:96.7.9":  {Integer, "", 0, func(t *smr.Telegram, v Value) { t.LongPowerFailures = v.Int }},
+	"1-0:99.97.0": {Buffer, "s", 0, setPowerFailureLog},
+	"1-0:32.32.0": {Integer, "", 0, func(t *smr.Telegram, v Value) { t.VoltageSagsPhase1 = v.Int }},
+	"1-0:52.32.0": {Integer, "", 0, func(t *smr.Telegram, v Value) { t.VoltageSagsPhase2 = v.Int }},
+	"1-0:72.32.0": {Integer, "", 0, func(t *smr.Telegram, v Value) { t.VoltageSagsPhase3 = v.Int }},
+	"1-0:32.36.0": {Integer, "", 0, func(t *smr.Telegram, v Value) { t.VoltageSwellsPhase1 = v.Int }},
+	"1-0:52.36.0": {Integer, "", 0, func(t *smr.Telegram, v Value) { t.VoltageSwellsPhase2 = v.Int }},
+	"1-0:72.36.0": {Integer, "", 0, func(t *smr.Telegram, v Value) { t.VoltageSwellsPhase3 = v.Int }},
+	"0-0:96.13.0": {OctetString, "", 0, func(t *smr.Telegram, v Value) { t.TextMessage = v.Text }},
+	"1-0:32.7.0":  {Number, "V", 3, func(t *smr.Telegram, v Value) { t.VoltagePhase1 = v.Int }},
+	"1-0:52.7.0":  {Number, "V", 3, func(t *smr.Telegram, v Value) { t.VoltagePhase2 = v.Int }},
+	"1-0:72.7.0":  {Number, "V", 3, func(t *smr.Telegram, v Value) { t.VoltagePhase3 = v.Int }},
+	"1-0:31.7.0":  {Number, "A", 3, func(t *smr.Telegram, v Value) { t.CurrentPhase1 = v.Int }},
+	"1-0:51.7.0":  {Number, "A", 3, func(t *smr.Telegram, v Value) { t.CurrentPhase2 = v.Int }},
+	"1-0:71.7.0":  {Number, "A", 3, func(t *smr.Telegram, v Value) { t.CurrentPhase3 = v.Int }},
+	"1-0:21.7.0":  {Number, "kW", 3, func(t *smr.Telegram, v Value) { t.PowerConsumptionPhase1 = v.Int }},
+	"1-0:41.7.0":  {Number, "kW", 3, func(t *smr.Telegram, v Value) { t.PowerConsumptionPhase2 = v.Int }},
+	"1-0:61.7.0":  {Number, "kW", 3, func(t *smr.Telegram, v Value) { t.PowerConsumptionPhase3 = v.Int }},
+	"1-0:22.7.0":  {Number, "kW", 3, func(t *smr.Telegram, v Value) { t.PowerDeliveryPhase1 = v.Int }},
+	"1-0:42.7.0":  {Number, "kW", 3, func(t *smr.Telegram, v Value) { t.PowerDeliveryPhase2 = v.Int }},
+	"1-0:62.7.0":  {Number, "kW", 3, func(t *smr.Telegram, v Value) { t.PowerDeliveryPhase3 = v.Int }},
+
+	// Belgian (e-MUCS) capacity tariff registers
+	"0-0:96.1.4": {Integer, "", 0, func(t *smr.Telegram, v Value) { capacityTariff(t).Version = v.Int }},
+	"1-0:1.4.0":  {Number, "kW", 3, func(t *smr.Telegram, v Value) { capacityTariff(t).CurrentAverageDemand = v.Int }},
+	"1-0:1.6.0":  {TimestampedNumber, "kW", 3, setMaximumDemand},
+	"0-0:98.1.0": {Buffer, "kW", 3, setMaximumDemandHistory},
+}
+
+func init() {
+	// The M-Bus registers are repeated for each of the four channels
+	for channel := 1; channel <= 4; channel++ {
+		c := int8(channel)
+		Registers[fmt.Sprintf("0-%d:24.1.0", c)] = Register{Integer, "", 0, func(t *smr.Telegram, v Value) {
+			mbusReading(t, c).DeviceType = v.Int
+		}}
+		Registers[fmt.Sprintf("0-%d:96.1.0", c)] = Register{OctetString, "", 0, func(t *smr.Telegram, v Value) {
+			mbusReading(t, c).EquipmentIdentifier = v.Text
+		}}
+		// Belgian meters send the equipment identifier as 96.1.1
+		Registers[fmt.Sprintf("0-%d:96.1.1", c)] = Register{OctetString, "", 0, func(t *smr.Telegram, v Value) {
+			mbusReading(t, c).EquipmentIdentifier = v.Text
+		}}
+		Registers[fmt.Sprintf("0-%d:24.2.1", c)] = Register{TimestampedNumber, "m3", 3, func(t *smr.Telegram, v Value) {
+			reading := mbusReading(t, c)
+			reading.Timestamp = v.Time
+			reading.Value = v.Int
+		}}
+		// Belgian meters send the (not temperature corrected) gas reading as 24.2.3
+		Registers[fmt.Sprintf("0-%d:24.2.3", c)] = Register{TimestampedNumber, "m3", 3, func(t *smr.Telegram, v Value) {
+			reading := mbusReading(t, c)
+			reading.Timestamp = v.Time
+			reading.Value = v.Int
+		}}
+		Registers[fmt.Sprintf("0-%d:24.3.0", c)] = Register{CapturedNumber, "m3", 3, func(t *smr.Telegram, v Value) {
+			reading := mbusReading(t, c)
+			reading.Timestamp = v.Time
+			reading.Value = v.Int
+		}}
+	}
+}
+
+// mbusReading returns the reading of the given M-Bus channel, adding it to the telegram when it does not exist yet.
+func mbusReading(t *smr.Telegram, channel int8) *smr.MBusReading {
+	for i := range t.MBus {
+		if t.MBus[i].Channel == channel {
+			return &t.MBus[i]
+		}
+	}
+	t.MBus = append(t.MBus, smr.MBusReading{Channel: channel})
+	return &t.MBus[len(t.MBus)-1]
+}
+
+func setPowerFailureLog(t *smr.Telegram, v Value) {
+	t.PowerFailureLog = make([]smr.PowerFailureEvent, 0, len(v.List))
+	for _, entry := range v.List {
+		if len(entry.List) != 2 {
+			continue
+		}
+		t.PowerFailureLog = append(t.PowerFailureLog, smr.PowerFailureEvent{End: entry.List[0].Time, Duration: entry.List[1].Int})
+	}
+}
+
+// capacityTariff returns the Belgian capacity tariff registers, adding them to the telegram when they do not exist yet.
+func capacityTariff(t *smr.Telegram) *smr.CapacityTariff {
+	if t.CapacityTariff == nil {
+		t.CapacityTariff = &smr.CapacityTariff{}
+	}
+	return t.CapacityTariff
+}
+
+func setMaximumDemand(t *smr.Telegram, v Value) {
+	capacity := capacityTariff(t)
+	capacity.MaximumDemandTimestamp = v.Time
+	capacity.MaximumDemand = v.Int
+}
+
+func setMaximumDemandHistory(t *smr.Telegram, v Value) {
+	capacity := capacityTariff(t)
+	capacity.MaximumDemandHistory = make([]smr.DemandPeak, 0, len(v.List))
+	for _, entry := range v.List {
+		if len(entry.List) != 3 {
+			continue
+		}
+		capacity.MaximumDemandHistory = append(capacity.MaximumDemandHistory, smr.DemandPeak{
+			PeriodEnd: entry.List[0].Time,
+			Timestamp: entry.List[1].Time,
+			Value:     entry.List[2].Int,
+		})
+	}
+}
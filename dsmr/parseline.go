@@ -0,0 +1,250 @@
+package dsmr
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+)
+
+// ErrUnknownRegister is returned when a line contains an OBIS code that is not in the register table
+var ErrUnknownRegister = errors.New("unknown register")
+
+// splitLine splits a line like `1-0:1.8.1(001234.567*kWh)` into the OBIS code and the values between the parentheses.
+func splitLine(line string) (string, []string, error) {
+	line = strings.TrimRight(line, "\r\n")
+
+	start := strings.IndexByte(line, '(')
+	if start <= 0 {
+		return "", nil, fmt.Errorf("line '%s' does not contain an OBIS code followed by a value", line)
+	}
+
+	code := line[:start]
+	var values []string
+	for start < len(line) {
+		if line[start] != '(' {
+			return "", nil, fmt.Errorf("expected '(' at position %d in line '%s'", start, line)
+		}
+		end := strings.IndexByte(line[start:], ')')
+		if end < 0 {
+			return "", nil, fmt.Errorf("missing ')' in line '%s'", line)
+		}
+		values = append(values, line[start+1:start+end])
+		start += end + 1
+	}
+
+	return code, values, nil
+}
+
+// ParseLine parses a single line of a telegram and stores the value in the telegram. The timestamps are interpreted in
+// the given location, see parseTimestamp.
+func ParseLine(msg *smr.Telegram, line string, loc *time.Location) error {
+	code, values, err := splitLine(line)
+	if err != nil {
+		return err
+	}
+
+	register, ok := Registers[code]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownRegister, code)
+	}
+
+	value, err := register.decode(values, loc)
+	if err != nil {
+		return fmt.Errorf("could not decode %s: %w", code, err)
+	}
+
+	register.Set(msg, value)
+	return nil
+}
+
+// decode decodes the raw values of a line according to the type of the register.
+func (r Register) decode(values []string, loc *time.Location) (Value, error) {
+	switch r.Type {
+	case Number:
+		if len(values) != 1 {
+			return Value{}, fmt.Errorf("expected 1 value, got %d", len(values))
+		}
+		number, err := parseNumber(values[0], r.Unit, r.Scale)
+		return Value{Int: number}, err
+	case Integer:
+		if len(values) != 1 {
+			return Value{}, fmt.Errorf("expected 1 value, got %d", len(values))
+		}
+		number, err := strconv.ParseInt(values[0], 10, 64)
+		return Value{Int: number}, err
+	case Timestamp:
+		if len(values) != 1 {
+			return Value{}, fmt.Errorf("expected 1 value, got %d", len(values))
+		}
+		timestamp, err := parseTimestamp(values[0], loc)
+		return Value{Time: timestamp}, err
+	case OctetString:
+		if len(values) != 1 {
+			return Value{}, fmt.Errorf("expected 1 value, got %d", len(values))
+		}
+		text, err := hex.DecodeString(values[0])
+		return Value{Text: string(text)}, err
+	case Buffer:
+		return parseBuffer(values, r.Unit, r.Scale, loc)
+	case TimestampedNumber:
+		if len(values) != 2 {
+			return Value{}, fmt.Errorf("expected 2 values, got %d", len(values))
+		}
+		timestamp, err := parseTimestamp(values[0], loc)
+		if err != nil {
+			return Value{}, err
+		}
+		number, err := parseNumber(values[1], r.Unit, r.Scale)
+		return Value{Time: timestamp, Int: number}, err
+	case CapturedNumber:
+		if len(values) != 7 {
+			return Value{}, fmt.Errorf("expected 7 values, got %d", len(values))
+		}
+		timestamp, err := parseTimestamp(values[0], loc)
+		if err != nil {
+			return Value{}, err
+		}
+		number, err := parseNumber(values[6]+"*"+values[5], r.Unit, r.Scale)
+		return Value{Time: timestamp, Int: number}, err
+	}
+	return Value{}, fmt.Errorf("unknown value type %d", r.Type)
+}
+
+// parseNumber parses a value like `001234.567*kWh` as an integer, multiplying the value by 10^scale. The unit must
+// match the given unit and the value may have at most `scale` decimals behind the decimal dot.
+func parseNumber(s string, unit string, scale int) (int64, error) {
+	number, u, found := strings.Cut(s, "*")
+	if !found || u != unit {
+		return 0, fmt.Errorf("expected a value with unit %s, got '%s'", unit, s)
+	}
+
+	integer, fraction, _ := strings.Cut(number, ".")
+	if len(fraction) > scale {
+		return 0, fmt.Errorf("expect value with a mantissa of at most length %d", scale)
+	}
+
+	value, err := strconv.ParseInt(integer+fraction+strings.Repeat("0", scale-len(fraction)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return value, nil
+}
+
+// unspecifiedTimestamp is sent by meters for a timestamp that is not known, e.g. for months without a recorded peak
+const unspecifiedTimestamp = "632525252525"
+
+// parseTimestamp parses a timestamp in the format YYMMDDhhmmssX, where the suffix X (W or S) indicates whether the
+// timestamp is in winter or summer time. Legacy meters do not send the suffix, their timestamps are in local time.
+//
+// The timestamps are interpreted in the given location, the suffix selects the right time during the hour that occurs
+// twice when the clocks are turned back. When no location is given the suffix is taken as +01:00 (W) or +02:00 (S)
+// and legacy timestamps are in the local time zone of the host.
+func parseTimestamp(s string, loc *time.Location) (time.Time, error) {
+	if strings.HasPrefix(s, unspecifiedTimestamp) {
+		return time.Time{}, nil
+	}
+	if len(s) == 12 {
+		if loc == nil {
+			loc = time.Local
+		}
+		timestamp, err := time.ParseInLocation("060102150405", s, loc)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return timestamp.In(time.UTC), nil
+	}
+	if len(s) != 13 {
+		return time.Time{}, fmt.Errorf("expected a timestamp of length 13, got '%s'", s)
+	}
+	if s[12] != 'W' && s[12] != 'S' {
+		return time.Time{}, fmt.Errorf("expected a timestamp ending with W or S, got '%s'", s)
+	}
+
+	if loc != nil {
+		timestamp, err := time.ParseInLocation("060102150405", s[:12], loc)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return selectOccurrence(timestamp, s[12] == 'S').In(time.UTC), nil
+	}
+
+	suffix := "+01:00"
+	if s[12] == 'S' {
+		suffix = "+02:00"
+	}
+	timestamp, err := time.ParseInLocation("060102150405Z07:00", s[:12]+suffix, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return timestamp.In(time.UTC), nil
+}
+
+// selectOccurrence returns the occurrence of the wall clock time of the timestamp that is (not) in summer time. The
+// wall clock time occurs twice in the hour in which the clocks are turned back, in which case the time package may
+// have picked the wrong one.
+func selectOccurrence(timestamp time.Time, summer bool) time.Time {
+	if timestamp.IsDST() == summer {
+		return timestamp
+	}
+	for _, shift := range []time.Duration{-time.Hour, time.Hour} {
+		candidate := timestamp.Add(shift)
+		if candidate.IsDST() == summer && candidate.Hour() == timestamp.Hour() {
+			return candidate
+		}
+	}
+	// The suffix does not match the time zone, e.g. a summer time suffix in the winter
+	return timestamp
+}
+
+// parseBuffer parses a profile generic buffer, like the power failure event log. The first value is the number of
+// entries, followed by the OBIS codes of the captured objects. Each entry consists of a timestamp per captured object
+// followed by a single value, e.g. `(2)(0-0:96.7.19)(101208152415W)(0000000240*s)(101208151004W)(0000000301*s)` or
+// `(1)(1-0:1.6.0)(1-0:1.6.0)(200501000000S)(200423192538S)(03.695*kW)`. Every entry is returned as a Value of which
+// the List holds the timestamps and the value.
+func parseBuffer(values []string, unit string, scale int, loc *time.Location) (Value, error) {
+	if len(values) < 1 {
+		return Value{}, errors.New("missing number of entries")
+	}
+	count, err := strconv.Atoi(values[0])
+	if err != nil {
+		return Value{}, err
+	}
+	if count == 0 {
+		return Value{List: []Value{}}, nil
+	}
+
+	objects := 0
+	for 1+objects < len(values) && strings.Contains(values[1+objects], ":") {
+		objects++
+	}
+	width := objects + 1
+	if objects == 0 || len(values) != 1+objects+width*count {
+		return Value{}, fmt.Errorf("expected %d entries of %d values, got %d values", count, width, len(values))
+	}
+
+	list := make([]Value, count)
+	for i := range list {
+		entry := values[1+objects+width*i : 1+objects+width*(i+1)]
+		columns := make([]Value, width)
+		for j := 0; j < objects; j++ {
+			timestamp, err := parseTimestamp(entry[j], loc)
+			if err != nil {
+				return Value{}, err
+			}
+			columns[j] = Value{Time: timestamp}
+		}
+		number, err := parseNumber(entry[objects], unit, scale)
+		if err != nil {
+			return Value{}, err
+		}
+		columns[objects] = Value{Int: number}
+		list[i] = Value{List: columns}
+	}
+	return Value{List: list}, nil
+}
@@ -0,0 +1,43 @@
+package dsmr
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseTimestampInLocation(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		timestamp string
+		expected  time.Time
+	}{
+		{"230326013000W", time.Date(2023, 3, 26, 0, 30, 0, 0, time.UTC)},
+		{"230326033000S", time.Date(2023, 3, 26, 1, 30, 0, 0, time.UTC)},
+		// The hour between 2:00 and 3:00 occurs twice when the clocks are turned back
+		{"231029023000S", time.Date(2023, 10, 29, 0, 30, 0, 0, time.UTC)},
+		{"231029023000W", time.Date(2023, 10, 29, 1, 30, 0, 0, time.UTC)},
+		{"231029033000W", time.Date(2023, 10, 29, 2, 30, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		for _, loc := range []*time.Location{amsterdam, nil} {
+			timestamp, err := parseTimestamp(test.timestamp, loc)
+			if err != nil {
+				t.Errorf("unexpected error for %s: %v", test.timestamp, err)
+			} else if !timestamp.Equal(test.expected) {
+				t.Errorf("expected %s for %s in %v, got %s", test.expected, test.timestamp, loc, timestamp)
+			}
+		}
+	}
+
+	// Legacy timestamps are in the local time of the meter
+	timestamp, err := parseTimestamp("230715120000", amsterdam)
+	if err != nil || !timestamp.Equal(time.Date(2023, 7, 15, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected legacy timestamp %s (%v)", timestamp, err)
+	}
+}
@@ -0,0 +1,284 @@
+package dsmr
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxFrameSize is the maximum size of a telegram in bytes when none is configured
+	DefaultMaxFrameSize = 8192
+	// DefaultFrameTimeout is the maximum time between the header and the end of a telegram when none is configured
+	DefaultFrameTimeout = 5 * time.Second
+)
+
+// Config configures how the telegram stream is read
+type Config struct {
+	// The version of the protocol, VersionAuto detects the version from the telegrams
+	Version Version
+	// The maximum size of a telegram in bytes, larger telegrams are discarded
+	MaxFrameSize int
+	// The maximum time between the header and the end of a telegram, telegrams that take longer are discarded
+	FrameTimeout time.Duration
+	// The time zone of the meter, e.g. Europe/Amsterdam. When nil, the timestamps are taken to be in CET/CEST.
+	Location *time.Location
+	// Clock determines the timestamp of the telegrams, the timestamp of the meter is used when nil
+	Clock *smr.Clock
+	// Metrics counts the invalid telegrams (crc_failures, resyncs, oversize_frames and frame_timeouts), may be nil
+	Metrics *smr.Metrics
+}
+
+// frame holds the state of the telegram that is being read
+type frame struct {
+	crc      uint16
+	telegram *smr.Telegram
+	header   string
+	// Legacy meters may send a value on the line after its OBIS code, so a line is only parsed when the next line is
+	// read.
+	pending string
+	size    int
+	start   time.Time
+	// The timer of the frame timeout, it sets expired when the telegram is not completed in time, also when the meter
+	// stalls and no next line arrives
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+// newFrame starts a frame with the given header line, the frame timeout is counted as soon as it expires
+func newFrame(header []byte, timeout time.Duration, metrics *smr.Metrics) *frame {
+	f := &frame{
+		crc:      CRC16(0, header),
+		telegram: &smr.Telegram{},
+		header:   string(header),
+		size:     len(header),
+		start:    time.Now(),
+	}
+	f.timer = time.AfterFunc(timeout, func() {
+		f.expired.Store(true)
+		log.Errorf("Telegram not completed within %s", timeout)
+		metrics.Add("frame_timeouts", 1)
+	})
+	return f
+}
+
+// stop stops the timer of the frame, it returns false when the frame timeout already expired
+func (f *frame) stop() bool {
+	if f == nil {
+		return true
+	}
+	return f.timer.Stop()
+}
+
+// ReadTelegramStream reads the lines from the reader, parses them and sends every valid telegram to the channel. A
+// telegram is valid when its CRC matches, or, for legacy meters that do not send a CRC, when it is complete. It returns
+// when the reader fails; the error is nil when the end of the stream is reached.
+//
+// A telegram starts at its header (e.g. `/KFM5KAIFA-METER`). Data before the header, like the rest of a telegram that
+// was partially received, is discarded. A telegram that exceeds the maximum size or is not completed within the frame
+// timeout is discarded as well, after which the stream is synchronised on the next header.
+func ReadTelegramStream(reader *bufio.Reader, ch chan smr.Telegram, config Config) error {
+	maxFrameSize := config.MaxFrameSize
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	frameTimeout := config.FrameTimeout
+	if frameTimeout <= 0 {
+		frameTimeout = DefaultFrameTimeout
+	}
+	metrics := config.Metrics
+
+	// The telegram that is being read, nil while waiting for a header
+	var f *frame
+	// Whether data was discarded since the last telegram, in which case the next header is a resynchronisation
+	discarded := false
+	defer func() { f.stop() }()
+
+	for {
+		// Read until next line feed (\n), this character is included in the resulting array
+		bytes, truncated, err := readLine(reader, maxFrameSize)
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(bytes) == 0 {
+			continue
+		}
+
+		if i := headerIndex(bytes); i >= 0 {
+			if f != nil || discarded || i > 0 {
+				log.Warn("Synchronised on telegram header, discarded partial telegram")
+				metrics.Add("resyncs", 1)
+			}
+			f.stop()
+			f = newFrame(bytes[i:], frameTimeout, metrics)
+			discarded = false
+			continue
+		}
+
+		if f == nil {
+			discarded = true
+			continue
+		}
+
+		// The timeout is counted by the timer of the frame
+		if f.expired.Load() {
+			f = nil
+			discarded = true
+			continue
+		}
+
+		f.size += len(bytes)
+		if truncated || f.size > maxFrameSize {
+			log.Errorf("Telegram exceeds the maximum size of %d bytes", maxFrameSize)
+			metrics.Add("oversize_frames", 1)
+			f.stop()
+			f = nil
+			discarded = true
+			continue
+		}
+
+		if bytes[0] != 0x21 {
+			f.crc = CRC16(f.crc, bytes)
+			line := string(bytes)
+			if line[0] == 0x28 {
+				f.pending = strings.TrimRight(f.pending, "\r\n") + line
+				continue
+			}
+			if f.pending != "" {
+				parseLine(f.telegram, f.pending, config.Location)
+			}
+			f.pending = line
+			continue
+		}
+
+		// When we get here, the line received contains a checksum (if the meter sends one) and the telegram is finished
+
+		if !f.stop() {
+			// The frame timeout expired after the previous line was read
+			f = nil
+			discarded = true
+			continue
+		}
+
+		if f.pending != "" {
+			parseLine(f.telegram, f.pending, config.Location)
+		}
+
+		version := config.Version
+		if version == VersionAuto {
+			version = detectVersion(f.header, f.telegram)
+		}
+
+		checksum := strings.TrimSpace(string(bytes[1:]))
+		if checksum == "" && !version.IsLegacy() {
+			log.Errorf("Missing CRC in DSMR %s telegram", version)
+			metrics.Add("crc_failures", 1)
+		} else if checksum != "" && !validCRC(f.crc, checksum) {
+			log.Error("CRC mismatch")
+			metrics.Add("crc_failures", 1)
+		} else {
+			// The telegram is valid; send it to the channel
+			f.telegram.Timestamp = config.Clock.Timestamp(f.telegram.Timestamp, f.start)
+			ch <- *f.telegram
+		}
+
+		// Wait for the header of the next telegram, in case of an invalid telegram better luck next telegram
+		f = nil
+	}
+}
+
+// ContainsTelegram returns whether the data contains a valid telegram, e.g. to detect the configuration of a serial port
+func ContainsTelegram(data []byte) bool {
+	ch := make(chan smr.Telegram)
+	found := make(chan bool)
+	go func() {
+		valid := false
+		for range ch {
+			valid = true
+		}
+		found <- valid
+	}()
+
+	ReadTelegramStream(bufio.NewReader(bytes.NewReader(data)), ch, Config{})
+	close(ch)
+	return <-found
+}
+
+// readLine reads the next line, including the line feed. A line that is longer than max is truncated and the rest of
+// it is discarded, in which case the second return value is true.
+func readLine(reader *bufio.Reader, max int) ([]byte, bool, error) {
+	var line []byte
+	truncated := false
+	for {
+		slice, err := reader.ReadSlice(0x0a)
+		if !truncated {
+			if n := max - len(line); len(slice) > n {
+				slice = slice[:n]
+				truncated = true
+			}
+			line = append(line, slice...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		return line, truncated, err
+	}
+}
+
+// headerIndex returns the position of the telegram header in the line, or -1 when the line does not contain a header.
+// The header starts with a `/` followed by the three letter manufacturer id and the baud rate identification, e.g.
+// `/ISk5\2MT382-1000`. The header may be preceded by garbage when bytes were dropped.
+func headerIndex(line []byte) int {
+	for i := 0; i+4 < len(line); i++ {
+		if line[i] == 0x2f && isLetter(line[i+1]) && isLetter(line[i+2]) && isLetter(line[i+3]) &&
+			line[i+4] >= '0' && line[i+4] <= '9' {
+			return i
+		}
+	}
+	return -1
+}
+
+func isLetter(b byte) bool {
+	return b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z'
+}
+
+// validCRC returns whether the checksum received after the `!` matches the CRC that was calculated over the telegram.
+func validCRC(crc uint16, checksum string) bool {
+	crc = CRC16(crc, []byte{0x21})
+	expectedCRC, err := strconv.ParseUint(checksum, 16, 16)
+	if err != nil {
+		log.Error(err)
+		return false
+	}
+	return uint16(expectedCRC) == crc
+}
+
+// parseLine parses a line of the telegram and logs the lines that could not be parsed. The empty line that follows the
+// header is skipped.
+func parseLine(telegram *smr.Telegram, line string, loc *time.Location) {
+	log.Debugf("line '%s'", line)
+
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+
+	err := ParseLine(telegram, line, loc)
+	if errors.Is(err, ErrUnknownRegister) {
+		log.Infof("Unparsed line '%s'", line)
+	} else if err != nil {
+		log.Error(err)
+	}
+}
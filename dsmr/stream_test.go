@@ -0,0 +1,115 @@
+package dsmr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+)
+
+// withCRC completes the telegram with its checksum line
+func withCRC(telegram string) string {
+	return fmt.Sprintf("%s!%04X\r\n", telegram, CRC16(0, []byte(telegram+"!")))
+}
+
+// readAll reads all telegrams from the input
+func readAll(t *testing.T, input string, config Config) []smr.Telegram {
+	ch := make(chan smr.Telegram)
+	done := make(chan error)
+	go func() {
+		done <- ReadTelegramStream(bufio.NewReader(strings.NewReader(input)), ch, config)
+	}()
+
+	var telegrams []smr.Telegram
+	for {
+		select {
+		case telegram := <-ch:
+			telegrams = append(telegrams, telegram)
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			return telegrams
+		}
+	}
+}
+
+func TestReadTelegramStreamResynchronises(t *testing.T) {
+	first := withCRC("/KFM5KAIFA-METER\r\n\r\n1-3:0.2.8(42)\r\n1-0:1.7.0(00.193*kW)\r\n")
+	second := withCRC("/KFM5KAIFA-METER\r\n\r\n1-3:0.2.8(42)\r\n1-0:1.7.0(00.200*kW)\r\n")
+
+	// The stream starts in the middle of a telegram, the first telegram lost a line feed and the last telegram is
+	// cut off
+	input := "1-0:1.8.1(001234.567*kWh)\r\n!1234\r\n" +
+		strings.Replace(first, "\r\n1-3", "1-3", 1) +
+		"garbage" + first +
+		second[:30] + second
+
+	metrics := smr.NewMetrics("test")
+	telegrams := readAll(t, input, Config{Metrics: metrics})
+
+	if len(telegrams) != 2 {
+		t.Fatalf("expected 2 telegrams, got %d", len(telegrams))
+	}
+	if telegrams[0].PowerConsumption != 193 || telegrams[1].PowerConsumption != 200 {
+		t.Errorf("unexpected telegrams %+v", telegrams)
+	}
+	if resyncs := metrics.Get("resyncs"); resyncs != 3 {
+		t.Errorf("expected 3 resyncs, got %d", resyncs)
+	}
+	if failures := metrics.Get("crc_failures"); failures != 1 {
+		t.Errorf("expected 1 CRC failure, got %d", failures)
+	}
+}
+
+func TestReadTelegramStreamDiscardsOversizeFrames(t *testing.T) {
+	telegram := withCRC("/KFM5KAIFA-METER\r\n\r\n1-3:0.2.8(42)\r\n1-0:1.7.0(00.193*kW)\r\n")
+	oversize := withCRC("/KFM5KAIFA-METER\r\n\r\n" + strings.Repeat("0-0:96.13.0()\r\n", 10))
+
+	metrics := smr.NewMetrics("test")
+	telegrams := readAll(t, oversize+telegram, Config{MaxFrameSize: 100, Metrics: metrics})
+
+	if len(telegrams) != 1 {
+		t.Fatalf("expected 1 telegram, got %d", len(telegrams))
+	}
+	if oversize := metrics.Get("oversize_frames"); oversize != 1 {
+		t.Errorf("expected 1 oversize frame, got %d", oversize)
+	}
+}
+
+// TestReadTelegramStreamFrameTimeout stalls the meter in the middle of a telegram, the timeout is counted without
+// waiting for the next line
+func TestReadTelegramStreamFrameTimeout(t *testing.T) {
+	telegram := withCRC("/KFM5KAIFA-METER\r\n\r\n1-3:0.2.8(42)\r\n1-0:1.7.0(00.193*kW)\r\n")
+	pipeReader, pipeWriter := io.Pipe()
+	defer pipeWriter.Close()
+
+	metrics := smr.NewMetrics("test")
+	ch := make(chan smr.Telegram)
+	go ReadTelegramStream(bufio.NewReader(pipeReader), ch, Config{FrameTimeout: 50 * time.Millisecond, Metrics: metrics})
+
+	pipeWriter.Write([]byte(telegram[:40]))
+	time.Sleep(200 * time.Millisecond)
+	if timeouts := metrics.Get("frame_timeouts"); timeouts != 1 {
+		t.Errorf("expected 1 frame timeout during the stall, got %d", timeouts)
+	}
+
+	// The rest of the stalled telegram is discarded, the next telegram is read
+	next := withCRC("/KFM5KAIFA-METER\r\n\r\n1-3:0.2.8(42)\r\n1-0:1.7.0(00.200*kW)\r\n")
+	go pipeWriter.Write([]byte(telegram[40:] + next))
+	select {
+	case received := <-ch:
+		if received.PowerConsumption != 200 {
+			t.Errorf("unexpected telegram %+v", received)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no telegram received")
+	}
+	if timeouts := metrics.Get("frame_timeouts"); timeouts != 1 {
+		t.Errorf("expected 1 frame timeout, got %d", timeouts)
+	}
+}
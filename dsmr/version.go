@@ -0,0 +1,87 @@
+package dsmr
+
+import (
+	"fmt"
+	"strings"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	"github.com/tarm/serial"
+)
+
+// Version is the version of the DSMR protocol that the meter speaks
+type Version int
+
+const (
+	// VersionAuto detects the version from the telegrams
+	VersionAuto Version = iota
+	Version22
+	Version30
+	Version40
+	Version50
+)
+
+var versionNames = map[Version]string{
+	VersionAuto: "auto",
+	Version22:   "2.2",
+	Version30:   "3.0",
+	Version40:   "4",
+	Version50:   "5",
+}
+
+// ParseVersion parses a version as it is used in the configuration, i.e. "auto", "2.2", "3.0", "4" or "5".
+func ParseVersion(s string) (Version, error) {
+	for version, name := range versionNames {
+		if s == name {
+			return version, nil
+		}
+	}
+	return VersionAuto, fmt.Errorf("unknown DSMR version '%s'", s)
+}
+
+func (v Version) String() string {
+	return versionNames[v]
+}
+
+// IsLegacy returns whether the version is older than DSMR 4. Legacy meters communicate at 9600 baud 7E1 and do not
+// send a CRC with their telegrams.
+func (v Version) IsLegacy() bool {
+	return v == Version22 || v == Version30
+}
+
+// SerialConfig returns the configuration of the serial port with the given name for this version. When the version is
+// detected automatically the baud rate is left at 0, so that the settings of both DSMR 4 and of legacy meters are
+// probed (see source.SerialOptionsFromEnv).
+func (v Version) SerialConfig(name string) *serial.Config {
+	if v.IsLegacy() {
+		return &serial.Config{
+			Name:     name,
+			Baud:     9600,
+			Size:     7,
+			Parity:   serial.ParityEven,
+			StopBits: serial.Stop1,
+		}
+	}
+	if v == VersionAuto {
+		return &serial.Config{Name: name}
+	}
+	return &serial.Config{
+		Name: name,
+		Baud: 115200,
+	}
+}
+
+// detectVersion determines the version of the protocol from the header line and the telegram. Only DSMR 4 and later
+// send their version (1-3:0.2.8). DSMR 2.2 and 3.0 can not be told apart and are handled the same, so a telegram
+// without a version is considered to be DSMR 3.0.
+func detectVersion(header string, telegram *smr.Telegram) Version {
+	switch {
+	case telegram.Version >= 50:
+		return Version50
+	case telegram.Version >= 40:
+		return Version40
+	case strings.Contains(header, "\\2"):
+		// The "\2" in the identification indicates the 115200 baud rate of DSMR 4 and later
+		return Version40
+	}
+	return Version30
+}
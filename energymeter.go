@@ -0,0 +1,146 @@
+package meterstanden
+
+import (
+	"io"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// EnergyMeterReadout represents a readout from an energy meter that is connected to the inverter (SunSpec model 201 -
+// 204), e.g. the export/import meter at the grid connection. The sign of the power depends on where the meter is
+// installed; at the grid connection a positive power is exported.
+type EnergyMeterReadout struct {
+	Timestamp time.Time `json:"time,omitempty"`
+	// The number of phases of the meter (1, 2 or 3), the fields of the phases that the meter does not have are 0
+	Phases      int8  `json:"phases,omitempty"`
+	Current     int64 `json:"current,omitempty"`     // mA
+	L1Current   int64 `json:"l1Current,omitempty"`   // mA
+	L2Current   int64 `json:"l2Current,omitempty"`   // mA
+	L3Current   int64 `json:"l3Current,omitempty"`   // mA
+	VoltageLN   int64 `json:"voltageLN,omitempty"`   // mV
+	L1NVoltage  int64 `json:"l1nVoltage,omitempty"`  // mV
+	L2NVoltage  int64 `json:"l2nVoltage,omitempty"`  // mV
+	L3NVoltage  int64 `json:"l3nVoltage,omitempty"`  // mV
+	VoltageLL   int64 `json:"voltageLL,omitempty"`   // mV
+	L1L2Voltage int64 `json:"l1l2Voltage,omitempty"` // mV
+	L2L3Voltage int64 `json:"l2l3Voltage,omitempty"` // mV
+	L3L1Voltage int64 `json:"l3l1Voltage,omitempty"` // mV
+	Frequency   int64 `json:"frequency,omitempty"`   // mHz
+	Power       int64 `json:"power,omitempty"`       // W
+	L1Power     int64 `json:"l1Power,omitempty"`     // W
+	L2Power     int64 `json:"l2Power,omitempty"`     // W
+	L3Power     int64 `json:"l3Power,omitempty"`     // W
+	Exported    int64 `json:"exported,omitempty"`    // Wh
+	L1Exported  int64 `json:"l1Exported,omitempty"`  // Wh
+	L2Exported  int64 `json:"l2Exported,omitempty"`  // Wh
+	L3Exported  int64 `json:"l3Exported,omitempty"`  // Wh
+	Imported    int64 `json:"imported,omitempty"`    // Wh
+	L1Imported  int64 `json:"l1Imported,omitempty"`  // Wh
+	L2Imported  int64 `json:"l2Imported,omitempty"`  // Wh
+	L3Imported  int64 `json:"l3Imported,omitempty"`  // Wh
+
+	// The source tag and the serial number of the meter, they are only written to Influx
+	Device string `json:"device,omitempty"`
+	Serial string `json:"serial,omitempty"`
+}
+
+type EnergyMeterReadoutHandler struct {
+	IMeasurementHandler[EnergyMeterReadout]
+	Device string
+}
+
+func (h EnergyMeterReadoutHandler) Name() string {
+	return deviceStream("energy-meter", h.Device)
+}
+
+func (h EnergyMeterReadoutHandler) CreatePoint(m EnergyMeterReadout) *write.Point {
+	point := influxdb2.NewPoint(
+		"energy-meter",
+		deviceTags(m.Device, m.Serial, "solar-edge-meter-1"),
+		map[string]interface{}{
+			"current":    float64(m.Current) / 1000.0,
+			"l1current":  float64(m.L1Current) / 1000.0,
+			"voltageLN":  float64(m.VoltageLN) / 1000.0,
+			"l1nvoltage": float64(m.L1NVoltage) / 1000.0,
+			"voltageLL":  float64(m.VoltageLL) / 1000.0,
+			"frequency":  float64(m.Frequency) / 1000.0,
+			"power":      float64(m.Power),
+			"l1power":    float64(m.L1Power),
+			"exported":   float64(m.Exported),
+			"l1exported": float64(m.L1Exported),
+			"imported":   float64(m.Imported),
+			"l1imported": float64(m.L1Imported),
+		},
+		m.Timestamp,
+	)
+
+	if m.Phases >= 2 {
+		point.AddField("l1l2voltage", float64(m.L1L2Voltage)/1000.0)
+		point.AddField("l2current", float64(m.L2Current)/1000.0)
+		point.AddField("l2nvoltage", float64(m.L2NVoltage)/1000.0)
+		point.AddField("l2power", float64(m.L2Power))
+		point.AddField("l2exported", float64(m.L2Exported))
+		point.AddField("l2imported", float64(m.L2Imported))
+	}
+	if m.Phases >= 3 {
+		point.AddField("l2l3voltage", float64(m.L2L3Voltage)/1000.0)
+		point.AddField("l3l1voltage", float64(m.L3L1Voltage)/1000.0)
+		point.AddField("l3current", float64(m.L3Current)/1000.0)
+		point.AddField("l3nvoltage", float64(m.L3NVoltage)/1000.0)
+		point.AddField("l3power", float64(m.L3Power))
+		point.AddField("l3exported", float64(m.L3Exported))
+		point.AddField("l3imported", float64(m.L3Imported))
+	}
+	return point
+}
+
+func (h EnergyMeterReadoutHandler) GetTimestamp(m EnergyMeterReadout) time.Time {
+	return m.Timestamp
+}
+
+func (h EnergyMeterReadoutHandler) WriteMeasurement(writer io.Writer, m EnergyMeterReadout, previous EnergyMeterReadout) error {
+	if err := WriteValue(writer, m.Timestamp.Unix(), previous.Timestamp.Unix()); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, int64(m.Phases), int64(previous.Phases)); err != nil {
+		return err
+	}
+	for _, values := range [][2]int64{
+		{m.Current, previous.Current},
+		{m.L1Current, previous.L1Current},
+		{m.L2Current, previous.L2Current},
+		{m.L3Current, previous.L3Current},
+		{m.VoltageLN, previous.VoltageLN},
+		{m.L1NVoltage, previous.L1NVoltage},
+		{m.L2NVoltage, previous.L2NVoltage},
+		{m.L3NVoltage, previous.L3NVoltage},
+		{m.VoltageLL, previous.VoltageLL},
+		{m.Frequency, previous.Frequency},
+		{m.Power, previous.Power},
+		{m.L1Power, previous.L1Power},
+		{m.L2Power, previous.L2Power},
+		{m.L3Power, previous.L3Power},
+		{m.Exported, previous.Exported},
+		{m.L1Exported, previous.L1Exported},
+		{m.L2Exported, previous.L2Exported},
+		{m.L3Exported, previous.L3Exported},
+		{m.Imported, previous.Imported},
+		{m.L1Imported, previous.L1Imported},
+		{m.L2Imported, previous.L2Imported},
+		{m.L3Imported, previous.L3Imported},
+		{m.L1L2Voltage, previous.L1L2Voltage},
+		{m.L2L3Voltage, previous.L2L3Voltage},
+		{m.L3L1Voltage, previous.L3L1Voltage},
+	} {
+		if err := WriteValue(writer, values[0], values[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h EnergyMeterReadoutHandler) ZeroMeasurement() EnergyMeterReadout {
+	return EnergyMeterReadout{Timestamp: time.Unix(0, 0)}
+}
@@ -3,19 +3,20 @@ module github.com/gmulders/smart-meter-readings
 go 1.19
 
 require (
+	github.com/eclipse/paho.golang v0.10.0
+	github.com/influxdata/influxdb-client-go/v2 v2.12.2
 	github.com/jackc/pgx/v4 v4.6.0
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/nats-io/nats.go v1.19.0
 	github.com/sirupsen/logrus v1.4.2
 	github.com/tarm/serial v0.0.0-20180830185346-98f6abe2eb07
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/deepmap/oapi-codegen v1.8.2 // indirect
-	github.com/eclipse/paho.golang v0.10.0 // indirect
 	github.com/goburrow/serial v0.1.0 // indirect
 	github.com/gorilla/websocket v1.4.2 // indirect
-	github.com/influxdata/influxdb-client-go/v2 v2.12.2 // indirect
 	github.com/influxdata/line-protocol v0.0.0-20200327222509-2487e7298839 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	golang.org/x/net v0.5.0 // indirect
@@ -38,7 +39,7 @@ require (
 	github.com/sethvargo/go-retry v0.2.4
 	github.com/simonvetter/modbus v1.6.0
 	golang.org/x/crypto v0.5.0 // indirect
-	golang.org/x/sys v0.4.0 // indirect
+	golang.org/x/sys v0.4.0
 	golang.org/x/text v0.6.0 // indirect
 	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
 	google.golang.org/protobuf v1.26.0 // indirect
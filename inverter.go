@@ -0,0 +1,102 @@
+package meterstanden
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// InverterStatus is the operating state of an inverter (SunSpec I_Status)
+type InverterStatus int8
+
+const (
+	InverterStatusUndefined InverterStatus = iota
+	InverterStatusOff
+	InverterStatusSleeping
+	InverterStatusStarting
+	InverterStatusProducing
+	InverterStatusThrottled
+	InverterStatusShuttingDown
+	InverterStatusFault
+	InverterStatusStandby
+)
+
+var inverterStatusNames = []string{
+	"Undefined",
+	"Off",
+	"Sleeping",
+	"Grid Monitoring",
+	"Producing",
+	"Producing (Throttled)",
+	"Shutting Down",
+	"Fault",
+	"Standby",
+}
+
+func (s InverterStatus) String() string {
+	if s < 0 || int(s) >= len(inverterStatusNames) {
+		return fmt.Sprintf("Unknown (%d)", s)
+	}
+	return inverterStatusNames[s]
+}
+
+// InverterEvent is a change of the status of an inverter. The vendor status holds the vendor specific code of the
+// status, e.g. the code of a fault.
+type InverterEvent struct {
+	Timestamp    time.Time      `json:"time,omitempty"`
+	Previous     InverterStatus `json:"previous"`
+	Status       InverterStatus `json:"status"`
+	VendorStatus int64          `json:"vendorStatus"`
+
+	// The name and the serial number of the inverter, they are only written to Influx
+	Device string `json:"device,omitempty"`
+	Serial string `json:"serial,omitempty"`
+}
+
+type InverterEventHandler struct {
+	IMeasurementHandler[InverterEvent]
+	Device string
+}
+
+func (h InverterEventHandler) Name() string {
+	return deviceStream("inverter-events", h.Device)
+}
+
+func (h InverterEventHandler) CreatePoint(m InverterEvent) *write.Point {
+	tags := deviceTags(m.Device, m.Serial, "solar-edge-1")
+	tags["status"] = m.Status.String()
+	return influxdb2.NewPoint(
+		"inverter-event",
+		tags,
+		map[string]interface{}{
+			"previous":     int64(m.Previous),
+			"status":       int64(m.Status),
+			"vendorStatus": m.VendorStatus,
+		},
+		m.Timestamp,
+	)
+}
+
+func (h InverterEventHandler) GetTimestamp(m InverterEvent) time.Time {
+	return m.Timestamp
+}
+
+func (h InverterEventHandler) WriteMeasurement(writer io.Writer, m InverterEvent, previous InverterEvent) error {
+	if err := WriteValue(writer, m.Timestamp.Unix(), previous.Timestamp.Unix()); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, int64(m.Previous), 0); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, int64(m.Status), 0); err != nil {
+		return err
+	}
+	return WriteValue(writer, m.VendorStatus, 0)
+}
+
+func (h InverterEventHandler) ZeroMeasurement() InverterEvent {
+	return InverterEvent{Timestamp: time.Unix(0, 0)}
+}
@@ -0,0 +1,99 @@
+package meterstanden
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// MBusReading represents the reading of a device (gas, water, heat) that is connected to one of the M-Bus channels of
+// the smart meter
+type MBusReading struct {
+	Timestamp           time.Time `json:"time,omitempty"`
+	Channel             int8      `json:"channel,omitempty"`
+	DeviceType          int64     `json:"deviceType,omitempty"`
+	EquipmentIdentifier string    `json:"equipmentIdentifier,omitempty"`
+	Value               int64     `json:"value,omitempty"` // dm3
+}
+
+// M-Bus device types (EN 13757-3)
+const (
+	MBusDeviceTypeGas       = 0x03
+	MBusDeviceTypeHeat      = 0x04
+	MBusDeviceTypeWarmWater = 0x06
+	MBusDeviceTypeWater     = 0x07
+)
+
+var MBUS_DEVICE_TYPE_MAP = map[int64]string{
+	MBusDeviceTypeGas:       "gas",
+	MBusDeviceTypeHeat:      "heat",
+	MBusDeviceTypeWarmWater: "warm-water",
+	MBusDeviceTypeWater:     "water",
+}
+
+type MBusReadingHandler struct {
+	IMeasurementHandler[MBusReading]
+}
+
+func (h MBusReadingHandler) Name() string {
+	return "mbus"
+}
+
+func (h MBusReadingHandler) CreatePoint(m MBusReading) *write.Point {
+	deviceType, ok := MBUS_DEVICE_TYPE_MAP[m.DeviceType]
+	if !ok {
+		deviceType = strconv.FormatInt(m.DeviceType, 10)
+	}
+
+	return influxdb2.NewPoint(
+		"mbus",
+		map[string]string{
+			"source":              "p1-meter",
+			"channel":             strconv.Itoa(int(m.Channel)),
+			"deviceType":          deviceType,
+			"equipmentIdentifier": m.EquipmentIdentifier,
+		},
+		map[string]interface{}{
+			"value": float64(m.Value) / 1000.0,
+		},
+		m.Timestamp,
+	)
+}
+
+func (h MBusReadingHandler) GetTimestamp(m MBusReading) time.Time {
+	return m.Timestamp
+}
+
+func (h MBusReadingHandler) WriteMeasurement(writer io.Writer, m MBusReading, previous MBusReading) error {
+	if err := WriteValue(writer, m.Timestamp.Unix(), previous.Timestamp.Unix()); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, int64(m.Channel), int64(previous.Channel)); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, m.DeviceType, previous.DeviceType); err != nil {
+		return err
+	}
+	if err := WriteString(writer, m.EquipmentIdentifier, previous.EquipmentIdentifier); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, m.Value, previous.Value); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (h MBusReadingHandler) ZeroMeasurement() MBusReading {
+	return zeroMBusReading
+}
+
+var zeroMBusReading = createZeroMBusReading()
+
+func createZeroMBusReading() MBusReading {
+	reading := MBusReading{}
+	reading.Timestamp = time.Unix(0, 0)
+	return reading
+}
@@ -16,12 +16,15 @@ import (
 )
 
 type IMeasurementHandler[M any] interface {
+	Name() string
 	GetTimestamp(m M) time.Time
 	CreatePoint(m M) *write.Point
 	WriteMeasurement(writer io.Writer, m M, previous M) error
 	ZeroMeasurement() M
 }
 
+// WriteMeasurementStream writes the measurements received on ch to Influx and to the monthly files of the handler, it
+// returns when ch is closed.
 func WriteMeasurementStream[M any](ctx context.Context, ch chan M, h IMeasurementHandler[M], client influxdb2.Client) {
 
 	lastMonth := -1
@@ -32,9 +35,7 @@ func WriteMeasurementStream[M any](ctx context.Context, ch chan M, h IMeasuremen
 
 	writeAPI := client.WriteAPI("ha", "electricity")
 
-	for {
-		telegram := <-ch
-
+	for telegram := range ch {
 		writeAPI.WritePoint(h.CreatePoint(telegram))
 
 		currentMonth := int(h.GetTimestamp(telegram).Month())
@@ -50,7 +51,8 @@ func WriteMeasurementStream[M any](ctx context.Context, ch chan M, h IMeasuremen
 				go gzipFile(filename)
 			}
 
-			filename, err := determineFilename(h.GetTimestamp(telegram))
+			var err error
+			filename, err = determineFilename(h.Name(), h.GetTimestamp(telegram))
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -77,12 +79,17 @@ func WriteMeasurementStream[M any](ctx context.Context, ch chan M, h IMeasuremen
 
 		previousTelegram = telegram
 	}
+
+	if file != nil {
+		writer.Flush()
+		file.Close()
+	}
 }
 
-func determineFilename(ts time.Time) (string, error) {
+func determineFilename(name string, ts time.Time) (string, error) {
 	// Return the first name for which no file exists
 	for i := 1; i <= 999; i++ {
-		filename := fmt.Sprintf("data/%d-%02d.%03d.bin", ts.Year(), ts.Month(), i)
+		filename := fmt.Sprintf("data/%s-%d-%02d.%03d.bin", name, ts.Year(), ts.Month(), i)
 		if _, err := os.Stat(filename); os.IsNotExist(err) {
 			return filename, nil
 		}
@@ -96,3 +103,16 @@ func WriteValue(writer io.Writer, newValue int64, oldValue int64) error {
 	_, err := writer.Write(buff[:n])
 	return err
 }
+
+// WriteString writes the string when it differs from the old string. A changed string is written as its length plus
+// one followed by its bytes, an unchanged string is written as a zero length.
+func WriteString(writer io.Writer, newValue string, oldValue string) error {
+	if newValue == oldValue {
+		return WriteValue(writer, 0, 0)
+	}
+	if err := WriteValue(writer, int64(len(newValue)+1), 0); err != nil {
+		return err
+	}
+	_, err := io.WriteString(writer, newValue)
+	return err
+}
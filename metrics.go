@@ -0,0 +1,83 @@
+package meterstanden
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// Metrics holds counters and gauges about the operation of a reader, e.g. the state of its connection. The values are
+// periodically written to Influx. All methods can be called on a nil Metrics, in which case nothing is recorded.
+type Metrics struct {
+	source string
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+// NewMetrics creates the metrics for the given source, which is used to tag the points in Influx
+func NewMetrics(source string) *Metrics {
+	return &Metrics{
+		source: source,
+		values: map[string]int64{},
+	}
+}
+
+// Add adds the delta to the counter with the given name
+func (m *Metrics) Add(name string, delta int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[name] += delta
+}
+
+// Set sets the gauge with the given name to the value
+func (m *Metrics) Set(name string, value int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[name] = value
+}
+
+// Get returns the current value of the counter or gauge with the given name
+func (m *Metrics) Get(name string) int64 {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[name]
+}
+
+// WriteMetricsStream writes the metrics to Influx at the given interval, until the context is cancelled
+func (m *Metrics) WriteMetricsStream(ctx context.Context, client influxdb2.Client, interval time.Duration) {
+	writeAPI := client.WriteAPI("ha", "electricity")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.mu.Lock()
+			fields := make(map[string]interface{}, len(m.values))
+			for name, value := range m.values {
+				fields[name] = value
+			}
+			m.mu.Unlock()
+
+			if len(fields) == 0 {
+				continue
+			}
+			log.Debugf("Metrics %v", fields)
+			writeAPI.WritePoint(influxdb2.NewPoint("metrics", map[string]string{"source": m.source}, fields, now))
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package meterstanden
+
+import (
+	"io"
+	"math"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+)
+
+// ModbusReadout represents a readout of a Modbus device that is read with a register map. The numbers are the fields
+// of the readout, the strings (e.g. the serial number) are its tags.
+type ModbusReadout struct {
+	Timestamp   time.Time         `json:"time,omitempty"`
+	Measurement string            `json:"measurement,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Fields      []ModbusField     `json:"fields,omitempty"`
+}
+
+// ModbusField is a value of a Modbus readout, in the unit of its register
+type ModbusField struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// ModbusReadoutHandler handles the readouts of a single register map, Stream is the name of the files the readouts are
+// written to
+type ModbusReadoutHandler struct {
+	IMeasurementHandler[ModbusReadout]
+	Stream string
+}
+
+func (h ModbusReadoutHandler) Name() string {
+	return h.Stream
+}
+
+func (h ModbusReadoutHandler) CreatePoint(m ModbusReadout) *write.Point {
+	fields := make(map[string]interface{}, len(m.Fields))
+	for _, f := range m.Fields {
+		// Influx does not accept NaN or infinite values
+		if math.IsNaN(f.Value) || math.IsInf(f.Value, 0) {
+			continue
+		}
+		fields[f.Name] = f.Value
+	}
+	return influxdb2.NewPoint(m.Measurement, m.Tags, fields, m.Timestamp)
+}
+
+func (h ModbusReadoutHandler) GetTimestamp(m ModbusReadout) time.Time {
+	return m.Timestamp
+}
+
+// WriteMeasurement writes the timestamp followed by the fields in thousandths of their unit. The fields of a register
+// map are always in the same order, so the field at the same index of the previous readout is subtracted.
+func (h ModbusReadoutHandler) WriteMeasurement(writer io.Writer, m ModbusReadout, previous ModbusReadout) error {
+	if err := WriteValue(writer, m.Timestamp.Unix(), previous.Timestamp.Unix()); err != nil {
+		return err
+	}
+	for i, f := range m.Fields {
+		var old int64
+		if i < len(previous.Fields) && previous.Fields[i].Name == f.Name {
+			old = milli(previous.Fields[i].Value)
+		}
+		if err := WriteValue(writer, milli(f.Value), old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h ModbusReadoutHandler) ZeroMeasurement() ModbusReadout {
+	return ModbusReadout{Timestamp: time.Unix(0, 0)}
+}
+
+func milli(v float64) int64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return int64(math.Round(v * 1000))
+}
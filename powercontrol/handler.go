@@ -0,0 +1,131 @@
+package powercontrol
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Command sets the active power limit, a limit of 100 percent clears it. The duration (e.g. `30m`) is optional.
+type Command struct {
+	Limit    *uint16 `json:"limit"`
+	Duration string  `json:"duration,omitempty"`
+}
+
+// ErrInvalidCommand is returned for a command that cannot be parsed
+var ErrInvalidCommand = errors.New("invalid command")
+
+// ErrUnauthorized is returned for an HTTP request without the token of the limiter
+var ErrUnauthorized = errors.New("unauthorized")
+
+// execute executes the command on the limiter, a command that cannot be parsed is logged as an invalid command
+func (l *Limiter) execute(source string, payload []byte) error {
+	command := Command{}
+	err := json.Unmarshal(payload, &command)
+	var duration time.Duration
+	switch {
+	case err != nil:
+		err = fmt.Errorf("could not parse the command: %v: %w", err, ErrInvalidCommand)
+	case command.Limit == nil:
+		err = fmt.Errorf("the command has no limit: %w", ErrInvalidCommand)
+	case command.Duration != "":
+		duration, err = time.ParseDuration(command.Duration)
+		if err != nil {
+			err = fmt.Errorf("could not parse duration '%s': %w", command.Duration, ErrInvalidCommand)
+		}
+	}
+	if err != nil {
+		l.reject(source, err)
+		return err
+	}
+	return l.Set(source, *command.Limit, duration)
+}
+
+// Register registers the routes of the limiter: `GET /api/power-limit` returns the state, `PUT /api/power-limit` with
+// a command sets the limit and `DELETE /api/power-limit` clears it. When the limiter has a token, PUT and DELETE
+// require the header `Authorization: Bearer <token>`.
+func (l *Limiter) Register(router *httprouter.Router) {
+	router.GET("/api/power-limit", l.get)
+	router.PUT("/api/power-limit", l.put)
+	router.DELETE("/api/power-limit", l.delete)
+}
+
+func (l *Limiter) get(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	l.writeState(w)
+}
+
+// authorize checks the token of a request that changes the limit, a request without the token is rejected
+func (l *Limiter) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if l.Token == "" {
+		return true
+	}
+	header := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token != header && subtle.ConstantTimeCompare([]byte(token), []byte(l.Token)) == 1 {
+		return true
+	}
+	l.reject("http "+r.RemoteAddr, fmt.Errorf("%s %s: %w", r.Method, r.URL.Path, ErrUnauthorized))
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, ErrUnauthorized.Error(), http.StatusUnauthorized)
+	return false
+}
+
+func (l *Limiter) put(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if !l.authorize(w, r) {
+		return
+	}
+	payload, err := io.ReadAll(io.LimitReader(r.Body, 4096))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := l.execute("http "+r.RemoteAddr, payload); err != nil {
+		writeError(w, err)
+		return
+	}
+	l.writeState(w)
+}
+
+func (l *Limiter) delete(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if !l.authorize(w, r) {
+		return
+	}
+	if err := l.Clear("http " + r.RemoteAddr); err != nil {
+		writeError(w, err)
+		return
+	}
+	l.writeState(w)
+}
+
+func (l *Limiter) writeState(w http.ResponseWriter) {
+	state, err := l.State()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// writeError writes a bad request for a command that is invalid or out of bounds, other errors are errors of the
+// inverter
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrInvalidCommand) || errors.Is(err, ErrOutOfBounds) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
+// HandleMessage executes the command in the payload of an MQTT message, the errors are logged by the limiter
+func (l *Limiter) HandleMessage(msg *paho.Publish) {
+	l.execute("mqtt "+msg.Topic, msg.Payload)
+}
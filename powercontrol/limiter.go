@@ -0,0 +1,243 @@
+// Package powercontrol limits the active power of a SolarEdge inverter, e.g. to curtail the production when the energy
+// prices are negative
+package powercontrol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/simonvetter/modbus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// RRCRStateAddress is the register of the state of the ripple control receiver of the inverter
+	RRCRStateAddress uint16 = 0xf000
+	// ActivePowerLimitAddress is the register of the active power limit in percent of the nominal power
+	ActivePowerLimitAddress uint16 = 0xf001
+
+	// NoLimit is the active power limit that does not limit the production
+	NoLimit uint16 = 100
+
+	DefaultTimeout       = 1 * time.Hour
+	DefaultRetryInterval = 1 * time.Minute
+
+	// DefaultAuditLog is the audit log that is opened when no path is given
+	DefaultAuditLog = "data/power-control-audit.log"
+)
+
+// ErrOutOfBounds is returned for a command that is outside the safety bounds of the limiter
+var ErrOutOfBounds = errors.New("out of bounds")
+
+// Device writes and reads the registers of the inverter, *bus.Unit implements it
+type Device interface {
+	ReadRegisters(address uint16, quantity uint16, regType modbus.RegType) ([]uint16, error)
+	WriteRegister(address uint16, value uint16) error
+}
+
+// State is the power control state of the inverter
+type State struct {
+	Limit     uint16 `json:"limit"`
+	RRCRState uint16 `json:"rrcr_state"`
+	// Expires is the time the watchdog reverts the limit, it is nil when no limit is set
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// AuditEntry is a line of the audit log, every command is logged whether it succeeds or not
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"`
+	Command  string    `json:"command"`
+	Limit    *uint16   `json:"limit,omitempty"`
+	Duration string    `json:"duration,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Limiter sets and clears the active power limit of the inverter. Every limit expires: the watchdog reverts the limit
+// when it is not renewed before its duration has passed, so a limit is never left behind when the controller that set
+// it stops.
+type Limiter struct {
+	device Device
+	audit  *json.Encoder
+
+	// Min is the lowest limit in percent that is accepted
+	Min uint16
+	// Timeout is the default and maximum duration of a limit
+	Timeout time.Duration
+	// RetryInterval is the time after which a failed revert is retried
+	RetryInterval time.Duration
+	// Token is the bearer token that HTTP requests that change the limit must send, they are not authenticated when
+	// it is empty
+	Token string
+
+	mu      sync.Mutex
+	expires time.Time
+	timer   *time.Timer
+}
+
+// NewLimiter creates the limiter of the device, the commands are logged to audit
+func NewLimiter(device Device, audit io.Writer) *Limiter {
+	return &Limiter{
+		device:        device,
+		audit:         json.NewEncoder(audit),
+		Timeout:       DefaultTimeout,
+		RetryInterval: DefaultRetryInterval,
+	}
+}
+
+// OpenAuditLog opens the audit log at path for appending, DefaultAuditLog is used when path is empty. Its directory is
+// created when it does not exist.
+func OpenAuditLog(path string) (*os.File, error) {
+	if path == "" {
+		path = DefaultAuditLog
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("could not create the directory of the audit log: %w", err)
+	}
+	audit, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the audit log %s: %w", path, err)
+	}
+	return audit, nil
+}
+
+// Set limits the active power to the percentage for the duration, the timeout of the limiter is used when the duration
+// is 0. A limit of 100 percent clears the limit.
+func (l *Limiter) Set(source string, limit uint16, duration time.Duration) error {
+	if limit >= NoLimit {
+		return l.Clear(source)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if duration == 0 {
+		duration = l.Timeout
+	}
+	var err error
+	switch {
+	case limit < l.Min:
+		err = fmt.Errorf("limit %d%% is below the minimum of %d%%: %w", limit, l.Min, ErrOutOfBounds)
+	case duration < 0 || duration > l.Timeout:
+		err = fmt.Errorf("duration %s is not between 0 and %s: %w", duration, l.Timeout, ErrOutOfBounds)
+	default:
+		err = l.write(limit)
+	}
+	l.log(source, "set", limit, duration, err)
+	if err != nil {
+		return err
+	}
+
+	l.stop()
+	l.expires = time.Now().Add(duration)
+	l.timer = time.AfterFunc(duration, l.revert)
+	return nil
+}
+
+// Clear removes the limit
+func (l *Limiter) Clear(source string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.write(NoLimit)
+	l.log(source, "clear", NoLimit, 0, err)
+	if err == nil {
+		l.stop()
+	}
+	return err
+}
+
+// State reads the power control state of the inverter
+func (l *Limiter) State() (State, error) {
+	registers, err := l.device.ReadRegisters(RRCRStateAddress, 2, modbus.HOLDING_REGISTER)
+	if err != nil {
+		return State{}, err
+	}
+	state := State{RRCRState: registers[0], Limit: registers[1]}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer != nil {
+		expires := l.expires
+		state.Expires = &expires
+	}
+	return state, nil
+}
+
+// revert is called by the watchdog when the limit expires, it is retried until the limit is cleared
+func (l *Limiter) revert() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The limit may have been renewed or cleared in the meantime
+	if l.timer == nil || time.Now().Before(l.expires) {
+		return
+	}
+
+	err := l.write(NoLimit)
+	l.log("watchdog", "revert", NoLimit, 0, err)
+	if err != nil {
+		l.timer = time.AfterFunc(l.RetryInterval, l.revert)
+		return
+	}
+	l.timer = nil
+}
+
+// stop stops the watchdog
+func (l *Limiter) stop() {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+}
+
+// write writes the limit and reads it back, an inverter without (enabled) power control may ignore the write
+func (l *Limiter) write(limit uint16) error {
+	if err := l.device.WriteRegister(ActivePowerLimitAddress, limit); err != nil {
+		return fmt.Errorf("could not write the active power limit: %w", err)
+	}
+	registers, err := l.device.ReadRegisters(ActivePowerLimitAddress, 1, modbus.HOLDING_REGISTER)
+	if err != nil {
+		return fmt.Errorf("could not read the active power limit: %w", err)
+	}
+	if registers[0] != limit {
+		return fmt.Errorf("the inverter has an active power limit of %d%% after writing %d%%", registers[0], limit)
+	}
+	return nil
+}
+
+// reject logs a command that could not be parsed
+func (l *Limiter) reject(source string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	log.Errorf("Invalid power control command by %s: %v", source, err)
+	l.writeAudit(AuditEntry{Time: time.Now(), Source: source, Command: "invalid", Error: err.Error()})
+}
+
+// log writes the command to the audit log and to the log
+func (l *Limiter) log(source string, command string, limit uint16, duration time.Duration, err error) {
+	entry := AuditEntry{Time: time.Now(), Source: source, Command: command, Limit: &limit}
+	if duration != 0 {
+		entry.Duration = duration.String()
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		log.Errorf("Power control %s to %d%% by %s failed: %v", command, limit, source, err)
+	} else {
+		log.Infof("Power control %s to %d%% by %s", command, limit, source)
+	}
+	l.writeAudit(entry)
+}
+
+func (l *Limiter) writeAudit(entry AuditEntry) {
+	if err := l.audit.Encode(entry); err != nil {
+		log.Errorf("Could not write the audit log: %v", err)
+	}
+}
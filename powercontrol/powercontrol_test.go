@@ -0,0 +1,344 @@
+package powercontrol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/gmulders/smart-meter-readings/bus"
+	"github.com/julienschmidt/httprouter"
+	"github.com/simonvetter/modbus"
+)
+
+// inverter is a stand-in for the power control registers of a SolarEdge inverter
+type inverter struct {
+	mu        sync.Mutex
+	registers map[uint16]uint16
+	// ignoreWrites makes the inverter ignore writes, as an inverter without power control does
+	ignoreWrites bool
+}
+
+func (i *inverter) HandleCoils(req *modbus.CoilsRequest) ([]bool, error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+func (i *inverter) HandleDiscreteInputs(req *modbus.DiscreteInputsRequest) ([]bool, error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+func (i *inverter) HandleHoldingRegisters(req *modbus.HoldingRegistersRequest) ([]uint16, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	result := make([]uint16, req.Quantity)
+	for j := range result {
+		address := req.Addr + uint16(j)
+		if _, ok := i.registers[address]; !ok {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		if req.IsWrite && !i.ignoreWrites {
+			i.registers[address] = req.Args[j]
+		}
+		result[j] = i.registers[address]
+	}
+	return result, nil
+}
+
+func (i *inverter) HandleInputRegisters(req *modbus.InputRegistersRequest) ([]uint16, error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+func (i *inverter) limit() uint16 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.registers[ActivePowerLimitAddress]
+}
+
+// audit is an audit log that can be read while the watchdog writes to it
+type audit struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (a *audit) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.buf.Write(p)
+}
+
+func (a *audit) entries(t *testing.T) []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(bytes.NewReader(a.buf.Bytes()))
+	for scanner.Scan() {
+		entry := AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// start starts an inverter stand-in and returns a limiter of it
+func start(t *testing.T) (*inverter, *Limiter, *audit) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	inv := &inverter{registers: map[uint16]uint16{RRCRStateAddress: 0, ActivePowerLimitAddress: NoLimit}}
+	server, err := modbus.NewServer(&modbus.ServerConfiguration{URL: "tcp://" + addr, MaxClients: 1}, inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Stop() })
+
+	b, units, err := bus.Open("tcp://"+addr, time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+
+	a := &audit{}
+	limiter := NewLimiter(b.Unit(units[0]), a)
+	limiter.Timeout = 200 * time.Millisecond
+	limiter.RetryInterval = 10 * time.Millisecond
+	return inv, limiter, a
+}
+
+func commands(entries []AuditEntry) string {
+	var s []string
+	for _, e := range entries {
+		s = append(s, e.Command)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestWatchdog(t *testing.T) {
+	inv, limiter, a := start(t)
+
+	if err := limiter.Set("test", 0, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if l := inv.limit(); l != 0 {
+		t.Errorf("limit is %d, expected 0", l)
+	}
+	state, err := limiter.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Limit != 0 || state.Expires == nil {
+		t.Errorf("unexpected state %+v", state)
+	}
+
+	// A renewed limit is not reverted by the watchdog of the previous limit
+	time.Sleep(30 * time.Millisecond)
+	if err := limiter.Set("test", 40, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if l := inv.limit(); l != 40 {
+		t.Errorf("limit is %d, expected 40", l)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if l := inv.limit(); l != NoLimit {
+		t.Errorf("limit is %d after the timeout, expected %d", l, NoLimit)
+	}
+	state, _ = limiter.State()
+	if state.Expires != nil {
+		t.Errorf("expected no expiry after the revert, got %v", state.Expires)
+	}
+	if c := commands(a.entries(t)); c != "set,set,revert" {
+		t.Errorf("audit log has %s", c)
+	}
+}
+
+func TestBounds(t *testing.T) {
+	inv, limiter, a := start(t)
+	limiter.Min = 10
+
+	if err := limiter.Set("test", 5, 0); !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("expected out of bounds, got %v", err)
+	}
+	if err := limiter.Set("test", 50, time.Second); !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("expected out of bounds, got %v", err)
+	}
+	if l := inv.limit(); l != NoLimit {
+		t.Errorf("limit is %d, expected %d", l, NoLimit)
+	}
+
+	// The default duration is the timeout
+	if err := limiter.Set("test", 10, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.Clear("test"); err != nil {
+		t.Fatal(err)
+	}
+	if l := inv.limit(); l != NoLimit {
+		t.Errorf("limit is %d, expected %d", l, NoLimit)
+	}
+
+	entries := a.entries(t)
+	if c := commands(entries); c != "set,set,set,clear" {
+		t.Errorf("audit log has %s", c)
+	}
+	if entries[0].Error == "" || entries[2].Error != "" || entries[2].Duration != "200ms" {
+		t.Errorf("unexpected audit log %+v", entries)
+	}
+}
+
+func TestIgnoredWrite(t *testing.T) {
+	inv, limiter, _ := start(t)
+	inv.mu.Lock()
+	inv.ignoreWrites = true
+	inv.mu.Unlock()
+
+	if err := limiter.Set("test", 0, 0); err == nil {
+		t.Errorf("expected an error when the inverter ignores the limit")
+	}
+	state, _ := limiter.State()
+	if state.Expires != nil {
+		t.Errorf("expected no watchdog for a failed limit")
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	inv, limiter, a := start(t)
+	router := httprouter.New()
+	limiter.Register(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	for _, test := range []struct {
+		method string
+		body   string
+		status int
+		limit  uint16
+	}{
+		{http.MethodGet, "", http.StatusOK, NoLimit},
+		{http.MethodPut, `{"limit": 30, "duration": "100ms"}`, http.StatusOK, 30},
+		{http.MethodPut, `{"limit": 20, "duration": "1h"}`, http.StatusBadRequest, 30},
+		{http.MethodPut, `{"duration": "1m"}`, http.StatusBadRequest, 30},
+		{http.MethodPut, `{"limit": "none"}`, http.StatusBadRequest, 30},
+		{http.MethodDelete, "", http.StatusOK, NoLimit},
+	} {
+		req, _ := http.NewRequest(test.method, server.URL+"/api/power-limit", strings.NewReader(test.body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s %s: status %d, expected %d", test.method, test.body, resp.StatusCode, test.status)
+		}
+		if l := inv.limit(); l != test.limit {
+			t.Errorf("%s %s: limit %d, expected %d", test.method, test.body, l, test.limit)
+		}
+	}
+
+	if c := commands(a.entries(t)); c != "set,set,invalid,invalid,clear" {
+		t.Errorf("audit log has %s", c)
+	}
+}
+
+func TestHTTPToken(t *testing.T) {
+	inv, limiter, a := start(t)
+	limiter.Token = "secret"
+	router := httprouter.New()
+	limiter.Register(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	for _, test := range []struct {
+		method        string
+		authorization string
+		status        int
+		limit         uint16
+	}{
+		{http.MethodGet, "", http.StatusOK, NoLimit},
+		{http.MethodPut, "", http.StatusUnauthorized, NoLimit},
+		{http.MethodPut, "Bearer wrong", http.StatusUnauthorized, NoLimit},
+		{http.MethodPut, "secret", http.StatusUnauthorized, NoLimit},
+		{http.MethodPut, "Bearer secret", http.StatusOK, 30},
+		{http.MethodDelete, "", http.StatusUnauthorized, 30},
+		{http.MethodDelete, "Bearer secret", http.StatusOK, NoLimit},
+	} {
+		req, _ := http.NewRequest(test.method, server.URL+"/api/power-limit", strings.NewReader(`{"limit": 30}`))
+		if test.authorization != "" {
+			req.Header.Set("Authorization", test.authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s '%s': status %d, expected %d", test.method, test.authorization, resp.StatusCode, test.status)
+		}
+		if l := inv.limit(); l != test.limit {
+			t.Errorf("%s '%s': limit %d, expected %d", test.method, test.authorization, l, test.limit)
+		}
+	}
+
+	if c := commands(a.entries(t)); c != "invalid,invalid,invalid,set,invalid,clear" {
+		t.Errorf("audit log has %s", c)
+	}
+}
+
+func TestMQTT(t *testing.T) {
+	inv, limiter, a := start(t)
+
+	limiter.HandleMessage(&paho.Publish{Topic: "solar/power-limit", Payload: []byte(`{"limit": 0}`)})
+	if l := inv.limit(); l != 0 {
+		t.Errorf("limit is %d, expected 0", l)
+	}
+	limiter.HandleMessage(&paho.Publish{Topic: "solar/power-limit", Payload: []byte(`{"limit": 100}`)})
+	if l := inv.limit(); l != NoLimit {
+		t.Errorf("limit is %d, expected %d", l, NoLimit)
+	}
+
+	entries := a.entries(t)
+	if c := commands(entries); c != "set,clear" || entries[0].Source != "mqtt solar/power-limit" {
+		t.Errorf("unexpected audit log %+v", entries)
+	}
+}
+
+func TestOpenAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "audit.log")
+	for i := 0; i < 2; i++ {
+		audit, err := OpenAuditLog(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintln(audit, "line")
+		audit.Close()
+	}
+
+	// The directory is created and the lines are appended
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "line\nline\n" {
+		t.Errorf("unexpected audit log '%s'", content)
+	}
+}
@@ -0,0 +1,156 @@
+package registermap
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/simonvetter/modbus"
+)
+
+// maxRegisters is the maximum number of registers that is read in a single request
+const maxRegisters = 125
+
+// Reader reads registers, *modbus.ModbusClient implements it
+type Reader interface {
+	ReadRegisters(address uint16, quantity uint16, regType modbus.RegType) ([]uint16, error)
+}
+
+// Value is the decoded value of a register
+type Value struct {
+	Register *Register
+	// Float is the scaled value of a number
+	Float float64
+	// Text is the value of a string
+	Text string
+}
+
+// request is a range of registers that is read at once
+type request struct {
+	function  Function
+	address   uint16
+	quantity  uint16
+	registers []*Register
+}
+
+// requests combines the registers in as few requests as possible
+func (m *Map) requests() []*request {
+	registers := make([]*Register, len(m.Registers))
+	for i := range m.Registers {
+		registers[i] = &m.Registers[i]
+	}
+	sort.SliceStable(registers, func(i, j int) bool {
+		if registers[i].Function != registers[j].Function {
+			return registers[i].Function < registers[j].Function
+		}
+		return registers[i].Address < registers[j].Address
+	})
+
+	var requests []*request
+	var current *request
+	for _, r := range registers {
+		if current != nil && current.function == r.Function &&
+			uint32(r.Address) <= uint32(current.address)+uint32(current.quantity)+uint32(m.MaxGap) &&
+			uint32(r.Address)+uint32(r.Size)-uint32(current.address) <= maxRegisters {
+			current.quantity = max(current.quantity, r.Address+r.Size-current.address)
+			current.registers = append(current.registers, r)
+			continue
+		}
+		current = &request{function: r.Function, address: r.Address, quantity: r.Size, registers: []*Register{r}}
+		requests = append(requests, current)
+	}
+	return requests
+}
+
+// Read reads the registers of the map and returns their values in the order of the map. The registers that are the
+// scale register of another register are not returned.
+func Read(reader Reader, m *Map) ([]Value, error) {
+	raw := make(map[*Register][]uint16, len(m.Registers))
+	for _, req := range m.requests() {
+		regType := modbus.HOLDING_REGISTER
+		if req.function == Input {
+			regType = modbus.INPUT_REGISTER
+		}
+		result, err := reader.ReadRegisters(req.address, req.quantity, regType)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range req.registers {
+			raw[r] = result[r.Address-req.address : r.Address-req.address+r.Size]
+		}
+	}
+
+	scales := map[string]bool{}
+	for _, r := range m.Registers {
+		if r.ScaleRegister != "" {
+			scales[r.ScaleRegister] = true
+		}
+	}
+
+	values := make([]Value, 0, len(m.Registers))
+	for i := range m.Registers {
+		r := &m.Registers[i]
+		if scales[r.Name] {
+			continue
+		}
+		v := Value{Register: r}
+		if r.Type == String {
+			v.Text = decodeString(raw[r])
+		} else {
+			scale := r.Scale
+			if r.ScaleRegister != "" {
+				sf := m.register(r.ScaleRegister)
+				scale += int(decodeNumber(sf, raw[sf]))
+			}
+			v.Float = decodeNumber(r, raw[r]) * math.Pow10(scale)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (m *Map) register(name string) *Register {
+	for i := range m.Registers {
+		if m.Registers[i].Name == name {
+			return &m.Registers[i]
+		}
+	}
+	return nil
+}
+
+// decodeNumber decodes the registers of a number, before it is scaled
+func decodeNumber(r *Register, registers []uint16) float64 {
+	var u uint64
+	for i := range registers {
+		w := registers[i]
+		if r.WordOrder == LittleEndian {
+			w = registers[len(registers)-1-i]
+		}
+		u = u<<16 | uint64(w)
+	}
+
+	switch r.Type {
+	case Uint16, Uint32, Uint64:
+		return float64(u)
+	case Int16:
+		return float64(int16(u))
+	case Int32:
+		return float64(int32(u))
+	case Int64:
+		return float64(int64(u))
+	case Float32:
+		return float64(math.Float32frombits(uint32(u)))
+	}
+	return 0
+}
+
+func decodeString(registers []uint16) string {
+	b := make([]byte, 0, 2*len(registers))
+	for _, w := range registers {
+		b = append(b, byte(w>>8), byte(w))
+	}
+	if i := strings.IndexByte(string(b), 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,216 @@
+// Package registermap reads Modbus devices with a register map that is loaded from a YAML or JSON file
+package registermap
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DataType determines how the registers of a value are decoded
+type DataType string
+
+const (
+	Uint16  DataType = "uint16"
+	Int16   DataType = "int16"
+	Uint32  DataType = "uint32"
+	Int32   DataType = "int32"
+	Uint64  DataType = "uint64"
+	Int64   DataType = "int64"
+	Float32 DataType = "float32"
+	String  DataType = "string"
+)
+
+// size returns the number of registers of the type, strings have a size of their own
+func (t DataType) size() uint16 {
+	switch t {
+	case Uint16, Int16:
+		return 1
+	case Uint32, Int32, Float32:
+		return 2
+	case Uint64, Int64:
+		return 4
+	}
+	return 0
+}
+
+// Function is the Modbus function that is used to read a register
+type Function string
+
+const (
+	Holding Function = "holding"
+	Input   Function = "input"
+)
+
+// WordOrder is the order of the registers of a value that spans multiple registers
+type WordOrder string
+
+const (
+	// BigEndian stores the most significant register first
+	BigEndian WordOrder = "big"
+	// LittleEndian stores the least significant register first
+	LittleEndian WordOrder = "little"
+)
+
+// Register describes a value of a device
+type Register struct {
+	Name    string   `json:"name" yaml:"name"`
+	Address uint16   `json:"address" yaml:"address"`
+	Type    DataType `json:"type" yaml:"type"`
+	// Size is the number of registers, it is only needed for strings
+	Size uint16 `json:"size,omitempty" yaml:"size,omitempty"`
+	// ScaleRegister is the name of the register that holds the scale factor (a power of 10) of the value
+	ScaleRegister string `json:"scale_register,omitempty" yaml:"scale_register,omitempty"`
+	// Scale is a fixed power of 10 the value is multiplied with, it is added to the value of the scale register
+	Scale     int       `json:"scale,omitempty" yaml:"scale,omitempty"`
+	Unit      string    `json:"unit,omitempty" yaml:"unit,omitempty"`
+	Function  Function  `json:"function,omitempty" yaml:"function,omitempty"`
+	WordOrder WordOrder `json:"word_order,omitempty" yaml:"word_order,omitempty"`
+}
+
+// Map describes the registers of a device
+type Map struct {
+	Name string `json:"name" yaml:"name"`
+	// Measurement is the name of the Influx measurement the values are written to, the name of the map by default
+	Measurement string `json:"measurement,omitempty" yaml:"measurement,omitempty"`
+	// The defaults of the registers that do not set a function or word order
+	Function  Function  `json:"function,omitempty" yaml:"function,omitempty"`
+	WordOrder WordOrder `json:"word_order,omitempty" yaml:"word_order,omitempty"`
+	// MaxGap is the number of unused registers that may be read to combine registers in a single request, it must
+	// only be set when the device allows reading the registers in between
+	MaxGap    uint16     `json:"max_gap,omitempty" yaml:"max_gap,omitempty"`
+	Registers []Register `json:"registers" yaml:"registers"`
+}
+
+//go:embed maps/*.yaml
+var builtin embed.FS
+
+// Builtin returns the names of the built-in register maps
+func Builtin() []string {
+	entries, _ := builtin.ReadDir("maps")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	return names
+}
+
+// Load returns the built-in register map with the given name, or else loads the register map from the given file. A
+// file with the extension .json is read as JSON, other files as YAML.
+func Load(name string) (*Map, error) {
+	if data, err := builtin.ReadFile(path.Join("maps", name+".yaml")); err == nil {
+		return Parse(data, false)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	m, err := Parse(data, strings.EqualFold(filepath.Ext(name), ".json"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return m, nil
+}
+
+// Parse parses and validates a register map
+func Parse(data []byte, isJSON bool) (*Map, error) {
+	m := &Map{}
+	var err error
+	if isJSON {
+		err = json.Unmarshal(data, m)
+	} else {
+		err = yaml.Unmarshal(data, m)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := m.init(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// init applies the defaults and validates the map
+func (m *Map) init() error {
+	if m.Measurement == "" {
+		m.Measurement = m.Name
+	}
+	if m.Measurement == "" {
+		m.Measurement = "modbus"
+	}
+	if m.Function == "" {
+		m.Function = Holding
+	}
+	if m.WordOrder == "" {
+		m.WordOrder = BigEndian
+	}
+	if len(m.Registers) == 0 {
+		return fmt.Errorf("register map '%s' has no registers", m.Name)
+	}
+
+	names := make(map[string]*Register, len(m.Registers))
+	for i := range m.Registers {
+		r := &m.Registers[i]
+		if r.Name == "" {
+			return fmt.Errorf("register at address %d has no name", r.Address)
+		}
+		if names[r.Name] != nil {
+			return fmt.Errorf("register '%s' is defined twice", r.Name)
+		}
+		names[r.Name] = r
+
+		if r.Function == "" {
+			r.Function = m.Function
+		}
+		if r.WordOrder == "" {
+			r.WordOrder = m.WordOrder
+		}
+		if r.Function != Holding && r.Function != Input {
+			return fmt.Errorf("register '%s' has unknown function '%s'", r.Name, r.Function)
+		}
+		if r.WordOrder != BigEndian && r.WordOrder != LittleEndian {
+			return fmt.Errorf("register '%s' has unknown word order '%s'", r.Name, r.WordOrder)
+		}
+		if r.Type == String {
+			if r.Size == 0 {
+				return fmt.Errorf("string register '%s' has no size", r.Name)
+			}
+		} else if r.Type.size() == 0 {
+			return fmt.Errorf("register '%s' has unknown type '%s'", r.Name, r.Type)
+		} else if r.Size != 0 && r.Size != r.Type.size() {
+			return fmt.Errorf("register '%s' of type %s has size %d", r.Name, r.Type, r.Size)
+		}
+		r.Size = max(r.Size, r.Type.size())
+		if uint32(r.Address)+uint32(r.Size) > 0x10000 {
+			return fmt.Errorf("register '%s' runs past the last address", r.Name)
+		}
+	}
+
+	for _, r := range m.Registers {
+		if r.ScaleRegister == "" {
+			continue
+		}
+		sf := names[r.ScaleRegister]
+		if sf == nil {
+			return fmt.Errorf("register '%s' has unknown scale register '%s'", r.Name, r.ScaleRegister)
+		}
+		if sf.Type != Int16 && sf.Type != Uint16 {
+			return fmt.Errorf("scale register '%s' must be an int16 or uint16", sf.Name)
+		}
+	}
+	return nil
+}
+
+func max(a, b uint16) uint16 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,139 @@
+package registermap
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/simonvetter/modbus"
+)
+
+// device holds the holding and input registers of a device and counts the requests
+type device struct {
+	holding  map[uint16]uint16
+	input    map[uint16]uint16
+	requests int
+}
+
+func (d *device) ReadRegisters(address uint16, quantity uint16, regType modbus.RegType) ([]uint16, error) {
+	registers := d.holding
+	if regType == modbus.INPUT_REGISTER {
+		registers = d.input
+	}
+	d.requests++
+	result := make([]uint16, quantity)
+	for i := range result {
+		v, ok := registers[address+uint16(i)]
+		if !ok {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		result[i] = v
+	}
+	return result, nil
+}
+
+func TestBuiltin(t *testing.T) {
+	names := Builtin()
+	if len(names) != 4 {
+		t.Errorf("expected 4 built-in maps, got %v", names)
+	}
+	for _, name := range names {
+		m, err := Load(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if m.Name != name {
+			t.Errorf("map %s has name %s", name, m.Name)
+		}
+		for _, req := range m.requests() {
+			if req.quantity > maxRegisters {
+				t.Errorf("%s: request at %d reads %d registers", name, req.address, req.quantity)
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, test := range []struct {
+		data  string
+		error string
+	}{
+		{`{"name": "x", "registers": []}`, "no registers"},
+		{`{"registers": [{"name": "a", "type": "uint8"}]}`, "unknown type"},
+		{`{"registers": [{"name": "a", "type": "string"}]}`, "no size"},
+		{`{"registers": [{"name": "a", "type": "uint32", "size": 1}]}`, "has size"},
+		{`{"registers": [{"name": "a", "type": "uint16"}, {"name": "a", "type": "uint16"}]}`, "defined twice"},
+		{`{"registers": [{"name": "a", "type": "uint16", "scale_register": "b"}]}`, "unknown scale register"},
+		{`{"registers": [{"name": "a", "type": "uint16", "function": "coil"}]}`, "unknown function"},
+		{`{"registers": [{"name": "a", "type": "uint16", "word_order": "middle"}]}`, "unknown word order"},
+		{`{"registers": [{"name": "a", "address": 65535, "type": "uint32"}]}`, "past the last address"},
+	} {
+		_, err := Parse([]byte(test.data), true)
+		if err == nil || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("%s: expected an error containing '%s', got %v", test.data, test.error, err)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	m, err := Parse([]byte(`
+name: test
+max_gap: 2
+registers:
+  - {name: serial, address: 0, type: string, size: 2}
+  - {name: power, address: 2, type: int16, scale_register: power_scale, unit: W}
+  - {name: power_scale, address: 3, type: int16}
+  - {name: energy, address: 6, type: uint32, scale: 2, unit: Wh}
+  - {name: swapped, address: 8, type: uint32, word_order: little}
+  - {name: far, address: 20, type: uint16}
+  - {name: voltage, address: 0x10, type: float32, function: input, unit: V}
+`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Measurement != "test" {
+		t.Errorf("measurement is %s, expected test", m.Measurement)
+	}
+
+	bits := math.Float32bits(230.5)
+	d := &device{
+		holding: map[uint16]uint16{
+			0: 'S'<<8 | 'N', 1: '1' << 8, 2: 0xfc18, 3: 0xffff, 4: 0, 5: 0, 6: 0x0001, 7: 0x0002, 8: 0x0003, 9: 0x0004,
+			20: 7,
+		},
+		input: map[uint16]uint16{0x10: uint16(bits >> 16), 0x11: uint16(bits)},
+	}
+
+	values, err := Read(d, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The registers 0 - 9 are read at once, register 20 and the input register on their own
+	if d.requests != 3 {
+		t.Errorf("expected 3 requests, got %d", d.requests)
+	}
+
+	expected := []struct {
+		name  string
+		float float64
+		text  string
+	}{
+		{"serial", 0, "SN1"},
+		{"power", -100, ""},
+		{"energy", 0x00010002 * 100, ""},
+		{"swapped", 0x00040003, ""},
+		{"far", 7, ""},
+		{"voltage", 230.5, ""},
+	}
+	if len(values) != len(expected) {
+		t.Fatalf("expected %d values, got %d", len(expected), len(values))
+	}
+	for i, e := range expected {
+		v := values[i]
+		if v.Register.Name != e.name || math.Abs(v.Float-e.float) > 1e-9 || v.Text != e.text {
+			t.Errorf("value %d is %s %v '%s', expected %s %v '%s'", i, v.Register.Name, v.Float, v.Text, e.name,
+				e.float, e.text)
+		}
+	}
+}
@@ -25,18 +25,37 @@ type SolarReadout struct {
 	VoltageDC     int64     `json:"voltageDC,omitempty"`     // mV
 	PowerDC       int64     `json:"powerDC,omitempty"`       // W
 	Temperature   int64     `json:"temperature,omitempty"`   // cC
+
+	// The number of phases of the inverter (1, 2 or 3), the fields of the phases that the inverter does not have are 0
+	Phases     int8  `json:"phases,omitempty"`
+	L2Current  int64 `json:"l2Current,omitempty"`  // mA
+	L3Current  int64 `json:"l3Current,omitempty"`  // mA
+	L2Voltage  int64 `json:"l2Voltage,omitempty"`  // mV
+	L3Voltage  int64 `json:"l3Voltage,omitempty"`  // mV
+	L2NVoltage int64 `json:"l2nVoltage,omitempty"` // mV
+	L3NVoltage int64 `json:"l3nVoltage,omitempty"` // mV
+
+	Status       InverterStatus `json:"status,omitempty"`
+	VendorStatus int64          `json:"vendorStatus,omitempty"`
+
+	// The name and the serial number of the inverter, they are only written to Influx
+	Device string `json:"device,omitempty"`
+	Serial string `json:"serial,omitempty"`
 }
 
 type SolarReadoutHandler struct {
 	IMeasurementHandler[SolarReadout]
+	Device string
+}
+
+func (h SolarReadoutHandler) Name() string {
+	return deviceStream("solar", h.Device)
 }
 
 func (h SolarReadoutHandler) CreatePoint(m SolarReadout) *write.Point {
-	return influxdb2.NewPoint(
+	point := influxdb2.NewPoint(
 		"solar",
-		map[string]string{
-			"source": "solar-edge-1",
-		},
+		deviceTags(m.Device, m.Serial, "solar-edge-1"),
 		map[string]interface{}{
 			"current":       float64(m.Current) / 1000.0,
 			"l1current":     float64(m.L1Current) / 1000.0,
@@ -52,9 +71,23 @@ func (h SolarReadoutHandler) CreatePoint(m SolarReadout) *write.Point {
 			"voltageDC":     float64(m.VoltageDC) / 1000.0,
 			"powerDC":       float64(m.PowerDC),
 			"temperature":   float64(m.Temperature) / 100.0,
+			"status":        int64(m.Status),
+			"vendorStatus":  m.VendorStatus,
 		},
 		m.Timestamp,
 	)
+
+	if m.Phases >= 2 {
+		point.AddField("l2current", float64(m.L2Current)/1000.0)
+		point.AddField("l2voltage", float64(m.L2Voltage)/1000.0)
+		point.AddField("l2nvoltage", float64(m.L2NVoltage)/1000.0)
+	}
+	if m.Phases >= 3 {
+		point.AddField("l3current", float64(m.L3Current)/1000.0)
+		point.AddField("l3voltage", float64(m.L3Voltage)/1000.0)
+		point.AddField("l3nvoltage", float64(m.L3NVoltage)/1000.0)
+	}
+	return point
 }
 
 func (h SolarReadoutHandler) GetTimestamp(t SolarReadout) time.Time {
@@ -107,6 +140,33 @@ func (h SolarReadoutHandler) WriteMeasurement(writer io.Writer, s SolarReadout,
 	if err := WriteValue(writer, s.Temperature, previous.Temperature); err != nil {
 		return err
 	}
+	if err := WriteValue(writer, int64(s.Phases), int64(previous.Phases)); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, s.L2Current, previous.L2Current); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, s.L3Current, previous.L3Current); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, s.L2Voltage, previous.L2Voltage); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, s.L3Voltage, previous.L3Voltage); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, s.L2NVoltage, previous.L2NVoltage); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, s.L3NVoltage, previous.L3NVoltage); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, int64(s.Status), int64(previous.Status)); err != nil {
+		return err
+	}
+	if err := WriteValue(writer, s.VendorStatus, previous.VendorStatus); err != nil {
+		return err
+	}
 	return nil
 }
 
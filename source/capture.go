@@ -0,0 +1,185 @@
+package source
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// captureMagic starts every capture file
+	captureMagic = "P1CAP1\n"
+	// maxReplayGap is the longest pause in a real time replay, e.g. when the reader was stopped during the capture
+	maxReplayGap = 1 * time.Minute
+	// maxRecordSize is the largest record that is replayed, a larger length means that the capture is corrupt
+	maxRecordSize = 1 << 20
+)
+
+// A capture file contains the bytes exactly as they were read from a source, together with the time they were
+// received. After the magic every read is stored as a record: the receive time as the varint encoded unix time in
+// nanoseconds, followed by the uvarint encoded length and the bytes.
+
+// CaptureReader passes the bytes read from the underlying reader through, and writes them to a capture file.
+type CaptureReader struct {
+	reader io.Reader
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// NewCaptureReader creates a reader that writes everything read from r to the capture file with the given name. The
+// file is appended to when it already exists.
+func NewCaptureReader(r io.Reader, filename string) (*CaptureReader, error) {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	c := &CaptureReader{
+		reader: r,
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}
+	if info.Size() == 0 {
+		c.writer.WriteString(captureMagic)
+	}
+	return c, nil
+}
+
+func (c *CaptureReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	if n > 0 {
+		if err := c.writeRecord(time.Now(), p[:n]); err != nil {
+			log.Errorf("Could not write capture: %v", err)
+		}
+	}
+	return n, err
+}
+
+// writeRecord writes the bytes with their receive time to the capture file
+func (c *CaptureReader) writeRecord(ts time.Time, data []byte) error {
+	buff := make([]byte, 2*binary.MaxVarintLen64)
+	n := binary.PutVarint(buff, ts.UnixNano())
+	n += binary.PutUvarint(buff[n:], uint64(len(data)))
+
+	if _, err := c.writer.Write(buff[:n]); err != nil {
+		return err
+	}
+	if _, err := c.writer.Write(data); err != nil {
+		return err
+	}
+	// Flush every record, so that nothing is lost when the reader is stopped
+	return c.writer.Flush()
+}
+
+// Close closes the capture file
+func (c *CaptureReader) Close() error {
+	if err := c.writer.Flush(); err != nil {
+		c.file.Close()
+		return err
+	}
+	return c.file.Close()
+}
+
+// ReplayReader reads the bytes from a capture file. In real time mode the bytes are returned at the pace they were
+// received, otherwise they are returned as fast as possible.
+type ReplayReader struct {
+	reader   *bufio.Reader
+	realtime bool
+	last     int64
+
+	// The part of the last record that was not read yet
+	buffer []byte
+}
+
+// NewReplayReader creates a reader that replays the capture read from r
+func NewReplayReader(r io.Reader, realtime bool) (*ReplayReader, error) {
+	reader := bufio.NewReader(r)
+
+	magic := make([]byte, len(captureMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != captureMagic {
+		return nil, errors.New("not a capture file")
+	}
+
+	return &ReplayReader{
+		reader:   reader,
+		realtime: realtime,
+	}, nil
+}
+
+func (r *ReplayReader) Read(p []byte) (int, error) {
+	for len(r.buffer) == 0 {
+		if err := r.readRecord(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.buffer)
+	r.buffer = r.buffer[n:]
+	return n, nil
+}
+
+// readRecord reads the next record, in real time mode it first waits as long as there was between the previous record
+// and this one.
+func (r *ReplayReader) readRecord() error {
+	ts, err := binary.ReadVarint(r.reader)
+	if err != nil {
+		return err
+	}
+	length, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if length > maxRecordSize {
+		return fmt.Errorf("corrupt capture: record of %d bytes exceeds the maximum of %d bytes", length, maxRecordSize)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r.reader, data); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	if r.realtime && r.last != 0 && ts > r.last {
+		gap := time.Duration(ts - r.last)
+		if gap > maxReplayGap {
+			gap = maxReplayGap
+		}
+		time.Sleep(gap)
+	}
+	r.last = ts
+	r.buffer = data
+	return nil
+}
+
+// unexpectedEOF converts an EOF in the middle of a record into an error
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// OpenReplay opens the capture file with the given name for replay
+func OpenReplay(filename string, realtime bool) (io.Reader, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := NewReplayReader(file, realtime)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("could not replay %s: %w", filename, err)
+	}
+	return reader, nil
+}
@@ -0,0 +1,115 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// capture reads everything from r through a capture reader that writes to the file
+func capture(t *testing.T, r io.Reader, filename string) {
+	c, err := NewCaptureReader(r, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(io.Discard, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCaptureReplay(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "p1.cap")
+	first := "/ISK5\\2M550T-1012\r\n\r\n1-0:1.8.1(001234.567*kWh)\r\n!1234\r\n"
+	second := "/ISK5\\2M550T-1012\r\n\r\n1-0:1.8.1(001234.568*kWh)\r\n!5678\r\n"
+
+	// Every read is a record of its own, a second capture is appended to the file
+	capture(t, iotest.HalfReader(strings.NewReader(first)), filename)
+	capture(t, iotest.OneByteReader(strings.NewReader(second)), filename)
+
+	replay, err := OpenReplay(filename, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != first+second {
+		t.Errorf("replayed '%s', expected '%s'", data, first+second)
+	}
+}
+
+func TestReplayRealtime(t *testing.T) {
+	var buffer bytes.Buffer
+	buffer.WriteString(captureMagic)
+	c := &CaptureReader{writer: bufio.NewWriter(&buffer)}
+	t0 := time.Now()
+	c.writeRecord(t0, []byte("a"))
+	c.writeRecord(t0.Add(100*time.Millisecond), []byte("b"))
+
+	replay, err := NewReplayReader(bytes.NewReader(buffer.Bytes()), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	data, err := io.ReadAll(replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "ab" {
+		t.Errorf("replayed '%s', expected 'ab'", data)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("replayed in %s, expected the pace of the capture", elapsed)
+	}
+}
+
+func TestReplayTruncated(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "p1.cap")
+	capture(t, strings.NewReader("/ISK5\\2M550T-1012\r\n"), filename)
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := NewReplayReader(bytes.NewReader(content[:len(content)-1]), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(replay); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected an unexpected EOF, got %v", err)
+	}
+
+	if _, err := NewReplayReader(strings.NewReader("/ISK5\\2M550T-1012\r\n"), false); err == nil {
+		t.Error("expected an error for a file that is not a capture")
+	}
+}
+
+func TestReplayCorruptLength(t *testing.T) {
+	var buffer bytes.Buffer
+	buffer.WriteString(captureMagic)
+	buff := make([]byte, 2*binary.MaxVarintLen64)
+	n := binary.PutVarint(buff, time.Now().UnixNano())
+	n += binary.PutUvarint(buff[n:], 1<<40)
+	buffer.Write(buff[:n])
+	buffer.WriteString("/ISK5\\2M550T-1012\r\n")
+
+	replay, err := NewReplayReader(&buffer, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(replay); err == nil || !strings.Contains(err.Error(), "corrupt capture") {
+		t.Errorf("expected an error for a corrupt length, got %v", err)
+	}
+}
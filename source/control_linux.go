@@ -0,0 +1,36 @@
+package source
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// setControlLines sets the RTS and DTR lines of the serial device, the lines that are nil are left alone
+func setControlLines(device string, rts *bool, dtr *bool) error {
+	// The modem control lines belong to the device, so they can be set through another file descriptor than the one of
+	// the open port
+	file, err := os.OpenFile(device, os.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fd := int(file.Fd())
+	for _, line := range []struct {
+		state *bool
+		bit   int
+	}{{rts, unix.TIOCM_RTS}, {dtr, unix.TIOCM_DTR}} {
+		if line.state == nil {
+			continue
+		}
+		request := uint(unix.TIOCMBIC)
+		if *line.state {
+			request = unix.TIOCMBIS
+		}
+		if err := unix.IoctlSetPointerInt(fd, request, line.bit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
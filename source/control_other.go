@@ -0,0 +1,10 @@
+//go:build !linux
+
+package source
+
+import "errors"
+
+// setControlLines is only supported on Linux
+func setControlLines(device string, rts *bool, dtr *bool) error {
+	return errors.New("setting the RTS and DTR lines is only supported on linux")
+}
@@ -0,0 +1,109 @@
+package source
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+const (
+	SerialBaudEnvName        = "SERIAL_BAUD"
+	SerialDataBitsEnvName    = "SERIAL_DATA_BITS"
+	SerialParityEnvName      = "SERIAL_PARITY"
+	SerialStopBitsEnvName    = "SERIAL_STOP_BITS"
+	SerialReadTimeoutEnvName = "SERIAL_READ_TIMEOUT"
+	SerialRTSEnvName         = "SERIAL_RTS"
+	SerialDTREnvName         = "SERIAL_DTR"
+)
+
+// SerialOptionsFromEnv returns the options of the serial port, the environment variables override the given default
+// configuration. When SERIAL_BAUD is `auto`, or when it is not set and the default configuration has no baud rate, the
+// AutoDetectConfigs are probed. The probe of the returned options is not set.
+func SerialOptionsFromEnv(config serial.Config) (SerialOptions, error) {
+	options := SerialOptions{}
+
+	baud := os.Getenv(SerialBaudEnvName)
+	if baud == "auto" || baud == "" && config.Baud == 0 {
+		options.Configs = AutoDetectConfigs
+	} else {
+		if baud != "" {
+			var err error
+			config.Baud, err = strconv.Atoi(baud)
+			if err != nil {
+				return options, fmt.Errorf("could not parse %s '%s'", SerialBaudEnvName, baud)
+			}
+		}
+
+		if dataBits := os.Getenv(SerialDataBitsEnvName); dataBits != "" {
+			size, err := strconv.ParseUint(dataBits, 10, 8)
+			if err != nil {
+				return options, fmt.Errorf("could not parse %s '%s'", SerialDataBitsEnvName, dataBits)
+			}
+			config.Size = byte(size)
+		}
+
+		if parity := os.Getenv(SerialParityEnvName); parity != "" {
+			// The parity is given by its name or its first letter, e.g. `even` or `E`
+			switch p := serial.Parity(strings.ToUpper(parity)[0]); p {
+			case serial.ParityNone, serial.ParityOdd, serial.ParityEven, serial.ParityMark, serial.ParitySpace:
+				config.Parity = p
+			default:
+				return options, fmt.Errorf("could not parse %s '%s'", SerialParityEnvName, parity)
+			}
+		}
+
+		switch stopBits := os.Getenv(SerialStopBitsEnvName); stopBits {
+		case "":
+		case "1":
+			config.StopBits = serial.Stop1
+		case "1.5":
+			config.StopBits = serial.Stop1Half
+		case "2":
+			config.StopBits = serial.Stop2
+		default:
+			return options, fmt.Errorf("could not parse %s '%s'", SerialStopBitsEnvName, stopBits)
+		}
+
+		options.Configs = []serial.Config{config}
+	}
+
+	if readTimeout := os.Getenv(SerialReadTimeoutEnvName); readTimeout != "" {
+		var err error
+		options.ReadTimeout, err = time.ParseDuration(readTimeout)
+		if err != nil {
+			return options, fmt.Errorf("could not parse %s '%s'", SerialReadTimeoutEnvName, readTimeout)
+		}
+	}
+
+	var err error
+	if options.RTS, err = parseLineState(SerialRTSEnvName); err != nil {
+		return options, err
+	}
+	if options.DTR, err = parseLineState(SerialDTREnvName); err != nil {
+		return options, err
+	}
+	return options, nil
+}
+
+// parseLineState parses the state of a control line like `1`, `on` or `false`, it returns nil when it is not set
+func parseLineState(envName string) (*bool, error) {
+	value := os.Getenv(envName)
+	switch strings.ToLower(value) {
+	case "":
+		return nil, nil
+	case "on", "high":
+		value = "true"
+	case "off", "low":
+		value = "false"
+	}
+
+	state, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s '%s'", envName, value)
+	}
+	return &state, nil
+}
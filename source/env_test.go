@@ -0,0 +1,36 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/tarm/serial"
+)
+
+func TestSerialOptionsFromEnv(t *testing.T) {
+	for _, test := range []struct {
+		baud    string
+		config  serial.Config
+		configs []serial.Config
+	}{
+		// Without a baud rate the configuration is detected
+		{"", serial.Config{}, AutoDetectConfigs},
+		{"auto", serial.Config{Baud: 9600, Size: 7}, AutoDetectConfigs},
+		{"", serial.Config{Baud: 115200}, []serial.Config{{Baud: 115200}}},
+		{"9600", serial.Config{}, []serial.Config{{Baud: 9600}}},
+	} {
+		t.Setenv(SerialBaudEnvName, test.baud)
+		options, err := SerialOptionsFromEnv(test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(options.Configs) != len(test.configs) {
+			t.Errorf("baud '%s': got %d configurations, expected %d", test.baud, len(options.Configs), len(test.configs))
+			continue
+		}
+		for i, config := range options.Configs {
+			if config != test.configs[i] {
+				t.Errorf("baud '%s': got %+v, expected %+v", test.baud, config, test.configs[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package source
+
+import (
+	"io"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	retry "github.com/sethvargo/go-retry"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReconnectingReader reads from a connection that is (re)opened when it fails. Opening the connection is retried with
+// an exponential backoff, so reading blocks until the connection is available again. The backoff is only reset once
+// data is received, so a connection that fails right after it is opened is not reopened in a tight loop.
+//
+// The state of the connection is reported in the metrics: the gauge `connected` and the counters `connects` and
+// `disconnects`.
+type ReconnectingReader struct {
+	name    string
+	open    func() (io.ReadCloser, error)
+	conn    io.ReadCloser
+	metrics *smr.Metrics
+
+	// Base and maximum delay between the attempts to open the connection
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	backoff    retry.Backoff
+}
+
+// NewReconnectingReader creates a reader that uses the open function to (re)open the connection. The name is used in
+// the logs, the metrics may be nil.
+func NewReconnectingReader(name string, metrics *smr.Metrics, open func() (io.ReadCloser, error)) *ReconnectingReader {
+	return &ReconnectingReader{
+		name:       name,
+		open:       open,
+		metrics:    metrics,
+		minBackoff: 1 * time.Second,
+		maxBackoff: 1 * time.Minute,
+	}
+}
+
+func (r *ReconnectingReader) Read(p []byte) (int, error) {
+	for {
+		if r.conn == nil {
+			r.connect()
+		}
+
+		n, err := r.conn.Read(p)
+		if n > 0 {
+			r.backoff = nil
+		}
+		if err == nil {
+			return n, nil
+		}
+
+		log.Errorf("Connection to %s lost: %v", r.name, err)
+		r.metrics.Set("connected", 0)
+		r.metrics.Add("disconnects", 1)
+		r.Close()
+
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// Close closes the current connection, the next read reopens it
+func (r *ReconnectingReader) Close() error {
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// connect opens the connection, retrying until it succeeds
+func (r *ReconnectingReader) connect() {
+	for {
+		if r.backoff == nil {
+			r.backoff = retry.WithCappedDuration(r.maxBackoff, retry.NewExponential(r.minBackoff))
+		} else {
+			delay, _ := r.backoff.Next()
+			time.Sleep(delay)
+		}
+
+		conn, err := r.open()
+		if err != nil {
+			log.Errorf("Could not connect to %s: %v", r.name, err)
+			continue
+		}
+
+		log.Infof("Connected to %s", r.name)
+		r.metrics.Set("connected", 1)
+		r.metrics.Add("connects", 1)
+		r.conn = conn
+		return
+	}
+}
@@ -0,0 +1,204 @@
+package source
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+	log "github.com/sirupsen/logrus"
+	"github.com/tarm/serial"
+)
+
+const (
+	// serialPollInterval is the read timeout of the serial port itself. The port returns EOF when nothing is received
+	// within this interval, which is used to check whether the read timeout of the connection expired.
+	serialPollInterval = 1 * time.Second
+	// DefaultProbeTimeout is the time in which a valid telegram must be received when probing a configuration, meters
+	// send a telegram at least every ten seconds
+	DefaultProbeTimeout = 15 * time.Second
+	// maxProbeSize is the amount of data that is kept while probing a configuration
+	maxProbeSize = 16384
+)
+
+// AutoDetectConfigs are the configurations that are probed to detect the configuration of the port: 115200 8N1
+// (DSMR 4 and later) and 9600 7E1 (DSMR 2.2 and 3.0)
+var AutoDetectConfigs = []serial.Config{
+	{Baud: 115200, Size: 8, Parity: serial.ParityNone, StopBits: serial.Stop1},
+	{Baud: 9600, Size: 7, Parity: serial.ParityEven, StopBits: serial.Stop1},
+}
+
+// SerialOptions configures how a serial port is opened
+type SerialOptions struct {
+	// The configurations of the port. When there is more than one, each configuration is tried in turn until Probe
+	// finds a valid telegram in the received data.
+	Configs []serial.Config
+	Probe   func(data []byte) bool
+	// The time after which the port is reopened when nothing is received, DefaultReadTimeout when zero
+	ReadTimeout time.Duration
+	// The state to set the RTS and DTR lines to, e.g. when one of them powers the data request line of the P1 port.
+	// The lines are left alone when nil.
+	RTS *bool
+	DTR *bool
+}
+
+// serialConn is a serial port that fails when no data is read within the timeout, so that a port that silently died
+// (e.g. because the cable was unplugged) is detected.
+type serialConn struct {
+	*serial.Port
+	timeout time.Duration
+}
+
+func (c serialConn) Read(p []byte) (int, error) {
+	deadline := time.Now().Add(c.timeout)
+	for {
+		n, err := c.Port.Read(p)
+		if n > 0 || !errors.Is(err, io.EOF) {
+			return n, err
+		}
+		if time.Now().After(deadline) {
+			return 0, fmt.Errorf("nothing received within %s", c.timeout)
+		}
+	}
+}
+
+// resolveDevice returns the device for the given name. The name may be a glob pattern, like
+// `/dev/serial/by-id/usb-FTDI_*`, in which case the first matching device is used. The pattern is resolved every time
+// the port is opened, so that the device is found again when it comes back under another name.
+func resolveDevice(name string) (string, error) {
+	if !strings.ContainsAny(name, "*?[") {
+		return name, nil
+	}
+
+	matches, err := filepath.Glob(name)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no device matches '%s'", name)
+	}
+	return matches[0], nil
+}
+
+// NewSerialReader creates a reader for the serial device with the given name (or glob pattern). The port is reopened
+// with backoff when it fails, e.g. because the cable was unplugged, or when nothing is received within the read timeout.
+func NewSerialReader(name string, options SerialOptions, metrics *smr.Metrics) *ReconnectingReader {
+	readTimeout := options.ReadTimeout
+	if readTimeout == 0 {
+		readTimeout = DefaultReadTimeout
+	}
+
+	// The configuration that was detected last is tried first when the port is reopened
+	configs := append([]serial.Config{}, options.Configs...)
+
+	return NewReconnectingReader(name, metrics, func() (io.ReadCloser, error) {
+		device, err := resolveDevice(name)
+		if err != nil {
+			return nil, err
+		}
+
+		// Log the device behind symlinks like the ones in /dev/serial/by-id, it may change when the cable is replugged
+		if target, err := filepath.EvalSymlinks(device); err == nil && target != device {
+			log.Infof("Opening %s (%s)", device, target)
+		} else {
+			log.Infof("Opening %s", device)
+		}
+
+		for i, config := range configs {
+			port, err := openPort(device, config, options)
+			if err != nil {
+				return nil, err
+			}
+			if len(configs) == 1 || options.Probe == nil {
+				return serialConn{port, readTimeout}, nil
+			}
+
+			data, ok := probe(port, options.Probe)
+			if !ok {
+				log.Infof("No telegram received at %s", describeConfig(config))
+				port.Close()
+				continue
+			}
+
+			log.Infof("Detected %s", describeConfig(config))
+			configs[0], configs[i] = configs[i], configs[0]
+			return probedConn{io.MultiReader(bytes.NewReader(data), serialConn{port, readTimeout}), port}, nil
+		}
+		return nil, fmt.Errorf("no telegram received at any of the configurations of %s", device)
+	})
+}
+
+// openPort opens the device with the given configuration and sets its control lines
+func openPort(device string, config serial.Config, options SerialOptions) (*serial.Port, error) {
+	config.Name = device
+	config.ReadTimeout = serialPollInterval
+	port, err := serial.OpenPort(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Not every adapter exposes the control lines, so the port is used anyway when they can not be set
+	if options.RTS != nil || options.DTR != nil {
+		if err := setControlLines(device, options.RTS, options.DTR); err != nil {
+			log.Warnf("Could not set the control lines of %s: %v", device, err)
+		}
+	}
+	return port, nil
+}
+
+// probe reads from the port until a valid telegram is received or the probe timeout expires. It returns the data
+// that was read.
+func probe(port *serial.Port, valid func(data []byte) bool) ([]byte, bool) {
+	var data []byte
+	buffer := make([]byte, 1024)
+	deadline := time.Now().Add(DefaultProbeTimeout)
+
+	for time.Now().Before(deadline) {
+		n, err := port.Read(buffer)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, false
+		}
+		if n == 0 {
+			continue
+		}
+
+		data = append(data, buffer[:n]...)
+		if len(data) > maxProbeSize {
+			data = data[len(data)-maxProbeSize/2:]
+		}
+		if valid(data) {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// probedConn returns the data that was read while probing the port before the data of the port itself
+type probedConn struct {
+	io.Reader
+	io.Closer
+}
+
+// describeConfig describes the configuration like `9600 7E1`
+func describeConfig(config serial.Config) string {
+	size := config.Size
+	if size == 0 {
+		size = serial.DefaultSize
+	}
+	parity := config.Parity
+	if parity == 0 {
+		parity = serial.ParityNone
+	}
+	stopBits := "1"
+	switch config.StopBits {
+	case serial.Stop1Half:
+		stopBits = "1.5"
+	case serial.Stop2:
+		stopBits = "2"
+	}
+	return fmt.Sprintf("%d %d%c%s", config.Baud, size, parity, stopBits)
+}
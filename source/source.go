@@ -0,0 +1,58 @@
+// Package source opens the source that the P1 telegrams are read from
+package source
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+)
+
+// DefaultReadTimeout is the time after which a connection is considered dead when nothing is received. Meters send a
+// telegram every second (DSMR 5) or every ten seconds (older versions).
+const DefaultReadTimeout = 30 * time.Second
+
+// Open opens the source with the given name. The name is either the name (or glob pattern) of a serial device, which is
+// opened with the given options, or a url like `tcp://host:port?read_timeout=30s`. The source is reopened when
+// the connection is lost, its state is reported in the metrics.
+//
+// A capture file (see CaptureReader) is replayed with a url like `replay://path/to/file?speed=realtime`, the speed is
+// either `realtime` or `max`. The replay ends at the end of the file.
+func Open(name string, options SerialOptions, metrics *smr.Metrics) (io.Reader, error) {
+	if !strings.Contains(name, "://") {
+		return NewSerialReader(name, options, metrics), nil
+	}
+
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "replay" {
+		switch speed := u.Query().Get("speed"); speed {
+		case "", "realtime":
+			return OpenReplay(u.Host+u.Path, true)
+		case "max":
+			return OpenReplay(u.Host+u.Path, false)
+		default:
+			return nil, fmt.Errorf("unsupported replay speed '%s'", speed)
+		}
+	}
+
+	readTimeout := DefaultReadTimeout
+	if s := u.Query().Get("read_timeout"); s != "" {
+		readTimeout, err = time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse read_timeout '%s': %w", s, err)
+		}
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		return NewTCPReader(u.Host, readTimeout, metrics), nil
+	}
+	return nil, fmt.Errorf("unsupported source '%s'", name)
+}
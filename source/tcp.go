@@ -0,0 +1,35 @@
+package source
+
+import (
+	"io"
+	"net"
+	"time"
+
+	smr "github.com/gmulders/smart-meter-readings"
+)
+
+// timeoutConn is a connection that fails when no data is read within the timeout, so that a connection that silently
+// died is detected.
+type timeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c timeoutConn) Read(p []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+// NewTCPReader creates a reader for the raw telegrams that a P1-to-Ethernet bridge (e.g. ser2net) exposes on the given
+// address. The connection is reopened when it fails or when nothing is received within the read timeout.
+func NewTCPReader(address string, readTimeout time.Duration, metrics *smr.Metrics) *ReconnectingReader {
+	return NewReconnectingReader("tcp://"+address, metrics, func() (io.ReadCloser, error) {
+		conn, err := net.DialTimeout("tcp", address, readTimeout)
+		if err != nil {
+			return nil, err
+		}
+		return timeoutConn{conn, readTimeout}, nil
+	})
+}
@@ -0,0 +1,294 @@
+package sunspec
+
+// PointType determines how the registers of a point are decoded
+type PointType int
+
+const (
+	Uint16 PointType = iota
+	Int16
+	Uint32
+	Int32
+	Acc32
+	Acc64
+	Float32
+	Enum16
+	Bitfield16
+	Bitfield32
+	ScaleFactor
+	String
+	Pad
+)
+
+// size returns the number of registers of the type, strings have a size of their own
+func (t PointType) size() uint16 {
+	switch t {
+	case Uint32, Int32, Acc32, Float32, Bitfield32:
+		return 2
+	case Acc64:
+		return 4
+	}
+	return 1
+}
+
+// Point describes a value of a model
+type Point struct {
+	Name string
+	// Offset is the offset of the point from the start of the model (after the ID and length)
+	Offset uint16
+	Size   uint16
+	Type   PointType
+	// ScaleFactor is the name of the point that holds the scale factor of this point, if any
+	ScaleFactor string
+	Unit        string
+}
+
+// Model describes the points of a model. The points are laid out in the order of the definition.
+type Model struct {
+	ID     uint16
+	Name   string
+	Points []Point
+	// Length is the number of registers of the fixed block
+	Length uint16
+	// Repeating are the points of a block that is repeated after the fixed block, until the end of the model
+	Repeating    []Point
+	RepeatLength uint16
+}
+
+// Models holds the known models by ID. Adding support for a model is done by adding it to this table.
+var Models = map[uint16]*Model{}
+
+func p(name string, t PointType, sf string, unit string) Point {
+	return Point{Name: name, Size: t.size(), Type: t, ScaleFactor: sf, Unit: unit}
+}
+
+func str(name string, size uint16) Point {
+	return Point{Name: name, Size: size, Type: String}
+}
+
+func sf(name string) Point {
+	return p(name, ScaleFactor, "", "")
+}
+
+func pad() Point {
+	return Point{Name: "Pad", Size: 1, Type: Pad}
+}
+
+// layout sets the offsets of the points and returns the total number of registers
+func layout(points []Point) uint16 {
+	var offset uint16
+	for i := range points {
+		points[i].Offset = offset
+		offset += points[i].Size
+	}
+	return offset
+}
+
+// define adds the model to the table
+func define(id uint16, name string, points ...Point) *Model {
+	model := &Model{ID: id, Name: name, Points: points, Length: layout(points)}
+	Models[id] = model
+	return model
+}
+
+// repeating sets the points of the repeating block of the model
+func (m *Model) repeating(points ...Point) *Model {
+	m.Repeating = points
+	m.RepeatLength = layout(points)
+	return m
+}
+
+func init() {
+	define(1, "Common",
+		str("Mn", 16), str("Md", 16), str("Opt", 8), str("Vr", 8), str("SN", 16), p("DA", Uint16, "", ""))
+
+	for id, name := range map[uint16]string{
+		101: "Single Phase Inverter",
+		102: "Split Phase Inverter",
+		103: "Three Phase Inverter",
+	} {
+		define(id, name,
+			p("A", Uint16, "A_SF", "A"), p("AphA", Uint16, "A_SF", "A"), p("AphB", Uint16, "A_SF", "A"),
+			p("AphC", Uint16, "A_SF", "A"), sf("A_SF"),
+			p("PPVphAB", Uint16, "V_SF", "V"), p("PPVphBC", Uint16, "V_SF", "V"), p("PPVphCA", Uint16, "V_SF", "V"),
+			p("PhVphA", Uint16, "V_SF", "V"), p("PhVphB", Uint16, "V_SF", "V"), p("PhVphC", Uint16, "V_SF", "V"),
+			sf("V_SF"),
+			p("W", Int16, "W_SF", "W"), sf("W_SF"),
+			p("Hz", Uint16, "Hz_SF", "Hz"), sf("Hz_SF"),
+			p("VA", Int16, "VA_SF", "VA"), sf("VA_SF"),
+			p("VAr", Int16, "VAr_SF", "var"), sf("VAr_SF"),
+			p("PF", Int16, "PF_SF", "Pct"), sf("PF_SF"),
+			p("WH", Acc32, "WH_SF", "Wh"), sf("WH_SF"),
+			p("DCA", Uint16, "DCA_SF", "A"), sf("DCA_SF"),
+			p("DCV", Uint16, "DCV_SF", "V"), sf("DCV_SF"),
+			p("DCW", Int16, "DCW_SF", "W"), sf("DCW_SF"),
+			p("TmpCab", Int16, "Tmp_SF", "C"), p("TmpSnk", Int16, "Tmp_SF", "C"), p("TmpTrns", Int16, "Tmp_SF", "C"),
+			p("TmpOt", Int16, "Tmp_SF", "C"), sf("Tmp_SF"),
+			p("St", Enum16, "", ""), p("StVnd", Enum16, "", ""),
+			p("Evt1", Bitfield32, "", ""), p("Evt2", Bitfield32, "", ""),
+			p("EvtVnd1", Bitfield32, "", ""), p("EvtVnd2", Bitfield32, "", ""),
+			p("EvtVnd3", Bitfield32, "", ""), p("EvtVnd4", Bitfield32, "", ""))
+	}
+
+	for id, name := range map[uint16]string{
+		111: "Single Phase Inverter (Float)",
+		112: "Split Phase Inverter (Float)",
+		113: "Three Phase Inverter (Float)",
+	} {
+		define(id, name,
+			p("A", Float32, "", "A"), p("AphA", Float32, "", "A"), p("AphB", Float32, "", "A"),
+			p("AphC", Float32, "", "A"),
+			p("PPVphAB", Float32, "", "V"), p("PPVphBC", Float32, "", "V"), p("PPVphCA", Float32, "", "V"),
+			p("PhVphA", Float32, "", "V"), p("PhVphB", Float32, "", "V"), p("PhVphC", Float32, "", "V"),
+			p("W", Float32, "", "W"), p("Hz", Float32, "", "Hz"), p("VA", Float32, "", "VA"),
+			p("VAr", Float32, "", "var"), p("PF", Float32, "", "Pct"), p("WH", Float32, "", "Wh"),
+			p("DCA", Float32, "", "A"), p("DCV", Float32, "", "V"), p("DCW", Float32, "", "W"),
+			p("TmpCab", Float32, "", "C"), p("TmpSnk", Float32, "", "C"), p("TmpTrns", Float32, "", "C"),
+			p("TmpOt", Float32, "", "C"),
+			p("St", Enum16, "", ""), p("StVnd", Enum16, "", ""),
+			p("Evt1", Bitfield32, "", ""), p("Evt2", Bitfield32, "", ""),
+			p("EvtVnd1", Bitfield32, "", ""), p("EvtVnd2", Bitfield32, "", ""),
+			p("EvtVnd3", Bitfield32, "", ""), p("EvtVnd4", Bitfield32, "", ""))
+	}
+
+	define(120, "Nameplate",
+		p("DERTyp", Enum16, "", ""),
+		p("WRtg", Uint16, "WRtg_SF", "W"), sf("WRtg_SF"),
+		p("VARtg", Uint16, "VARtg_SF", "VA"), sf("VARtg_SF"),
+		p("VArRtgQ1", Int16, "VArRtg_SF", "var"), p("VArRtgQ2", Int16, "VArRtg_SF", "var"),
+		p("VArRtgQ3", Int16, "VArRtg_SF", "var"), p("VArRtgQ4", Int16, "VArRtg_SF", "var"), sf("VArRtg_SF"),
+		p("ARtg", Uint16, "ARtg_SF", "A"), sf("ARtg_SF"),
+		p("PFRtgQ1", Int16, "PFRtg_SF", "cos()"), p("PFRtgQ2", Int16, "PFRtg_SF", "cos()"),
+		p("PFRtgQ3", Int16, "PFRtg_SF", "cos()"), p("PFRtgQ4", Int16, "PFRtg_SF", "cos()"), sf("PFRtg_SF"),
+		p("WHRtg", Uint16, "WHRtg_SF", "Wh"), sf("WHRtg_SF"),
+		p("AhrRtg", Uint16, "AhrRtg_SF", "AH"), sf("AhrRtg_SF"),
+		p("MaxChaRte", Uint16, "MaxChaRte_SF", "W"), sf("MaxChaRte_SF"),
+		p("MaxDisChaRte", Uint16, "MaxDisChaRte_SF", "W"), sf("MaxDisChaRte_SF"),
+		pad())
+
+	define(121, "Basic Settings",
+		p("WMax", Uint16, "WMax_SF", "W"), p("VRef", Uint16, "VRef_SF", "V"),
+		p("VRefOfs", Int16, "VRefOfs_SF", "V"), p("VMax", Uint16, "VMinMax_SF", "V"),
+		p("VMin", Uint16, "VMinMax_SF", "V"), p("VAMax", Uint16, "VAMax_SF", "VA"),
+		p("VArMaxQ1", Int16, "VArMax_SF", "var"), p("VArMaxQ2", Int16, "VArMax_SF", "var"),
+		p("VArMaxQ3", Int16, "VArMax_SF", "var"), p("VArMaxQ4", Int16, "VArMax_SF", "var"),
+		p("WGra", Uint16, "WGra_SF", "% WMax/sec"),
+		p("PFMinQ1", Int16, "PFMin_SF", "cos()"), p("PFMinQ2", Int16, "PFMin_SF", "cos()"),
+		p("PFMinQ3", Int16, "PFMin_SF", "cos()"), p("PFMinQ4", Int16, "PFMin_SF", "cos()"),
+		p("VArAct", Enum16, "", ""), p("ClcTotVA", Enum16, "", ""),
+		p("MaxRmpRte", Uint16, "MaxRmpRte_SF", "% WGra"), p("ECPNomHz", Uint16, "ECPNomHz_SF", "Hz"),
+		p("ConnPh", Enum16, "", ""),
+		sf("WMax_SF"), sf("VRef_SF"), sf("VRefOfs_SF"), sf("VMinMax_SF"), sf("VAMax_SF"), sf("VArMax_SF"),
+		sf("WGra_SF"), sf("PFMin_SF"), sf("MaxRmpRte_SF"), sf("ECPNomHz_SF"))
+
+	define(122, "Measurements Status",
+		p("PVConn", Bitfield16, "", ""), p("StorConn", Bitfield16, "", ""), p("ECPConn", Bitfield16, "", ""),
+		p("ActWh", Acc64, "", "Wh"), p("ActVAh", Acc64, "", "VAh"),
+		p("ActVArhQ1", Acc64, "", "varh"), p("ActVArhQ2", Acc64, "", "varh"),
+		p("ActVArhQ3", Acc64, "", "varh"), p("ActVArhQ4", Acc64, "", "varh"),
+		p("VArAval", Int16, "VArAval_SF", "var"), sf("VArAval_SF"),
+		p("WAval", Uint16, "WAval_SF", "var"), sf("WAval_SF"),
+		p("StSetLimMsk", Bitfield32, "", ""), p("StActCtl", Bitfield32, "", ""),
+		str("TmSrc", 4), p("Tms", Uint32, "", "Secs"), p("RtSt", Bitfield16, "", ""),
+		p("Ris", Uint16, "Ris_SF", "ohms"), sf("Ris_SF"))
+
+	define(123, "Immediate Controls",
+		p("Conn_WinTms", Uint16, "", "Secs"), p("Conn_RvrtTms", Uint16, "", "Secs"), p("Conn", Enum16, "", ""),
+		p("WMaxLimPct", Uint16, "WMaxLimPct_SF", "% WMax"), p("WMaxLimPct_WinTms", Uint16, "", "Secs"),
+		p("WMaxLimPct_RvrtTms", Uint16, "", "Secs"), p("WMaxLimPct_RmpTms", Uint16, "", "Secs"),
+		p("WMaxLim_Ena", Enum16, "", ""),
+		p("OutPFSet", Int16, "OutPFSet_SF", "cos()"), p("OutPFSet_WinTms", Uint16, "", "Secs"),
+		p("OutPFSet_RvrtTms", Uint16, "", "Secs"), p("OutPFSet_RmpTms", Uint16, "", "Secs"),
+		p("OutPFSet_Ena", Enum16, "", ""),
+		p("VArWMaxPct", Int16, "VArPct_SF", "% WMax"), p("VArMaxPct", Int16, "VArPct_SF", "% VArMax"),
+		p("VArAvalPct", Int16, "VArPct_SF", "% VArAval"), p("VArPct_WinTms", Uint16, "", "Secs"),
+		p("VArPct_RvrtTms", Uint16, "", "Secs"), p("VArPct_RmpTms", Uint16, "", "Secs"),
+		p("VArPct_Mod", Enum16, "", ""), p("VArPct_Ena", Enum16, "", ""),
+		sf("WMaxLimPct_SF"), sf("OutPFSet_SF"), sf("VArPct_SF"))
+
+	define(124, "Storage",
+		p("WChaMax", Uint16, "WChaMax_SF", "W"), p("WChaGra", Uint16, "WChaDisChaGra_SF", "% WChaMax/sec"),
+		p("WDisChaGra", Uint16, "WChaDisChaGra_SF", "% WChaMax/sec"), p("StorCtl_Mod", Bitfield16, "", ""),
+		p("VAChaMax", Uint16, "VAChaMax_SF", "VA"), p("MinRsvPct", Uint16, "MinRsvPct_SF", "% WChaMax"),
+		p("ChaState", Uint16, "ChaState_SF", "% AhrRtg"), p("StorAval", Uint16, "StorAval_SF", "AH"),
+		p("InBatV", Uint16, "InBatV_SF", "V"), p("ChaSt", Enum16, "", ""),
+		p("OutWRte", Int16, "InOutWRte_SF", "% WDisChaMax"), p("InWRte", Int16, "InOutWRte_SF", "% WChaMax"),
+		p("InOutWRte_WinTms", Uint16, "", "Secs"), p("InOutWRte_RvrtTms", Uint16, "", "Secs"),
+		p("InOutWRte_RmpTms", Uint16, "", "Secs"), p("ChaGriSet", Enum16, "", ""),
+		sf("WChaMax_SF"), sf("WChaDisChaGra_SF"), sf("VAChaMax_SF"), sf("MinRsvPct_SF"), sf("ChaState_SF"),
+		sf("StorAval_SF"), sf("InBatV_SF"), sf("InOutWRte_SF"))
+
+	define(160, "Multiple MPPT Inverter Extension",
+		sf("DCA_SF"), sf("DCV_SF"), sf("DCW_SF"), sf("DCWH_SF"),
+		p("Evt", Bitfield32, "", ""), p("N", Uint16, "", ""), p("TmsPer", Uint16, "", ""),
+	).repeating(
+		p("ID", Uint16, "", ""), str("IDStr", 8),
+		p("DCA", Uint16, "DCA_SF", "A"), p("DCV", Uint16, "DCV_SF", "V"), p("DCW", Uint16, "DCW_SF", "W"),
+		p("DCWH", Acc32, "DCWH_SF", "Wh"), p("Tms", Uint32, "", "Secs"), p("Tmp", Int16, "", "C"),
+		p("DCSt", Enum16, "", ""), p("DCEvt", Bitfield32, "", ""))
+
+	for id, name := range map[uint16]string{
+		201: "Single Phase Meter",
+		202: "Split Phase Meter",
+		203: "Wye 3P1N Three Phase Meter",
+		204: "Delta 3P Three Phase Meter",
+	} {
+		define(id, name,
+			p("A", Int16, "A_SF", "A"), p("AphA", Int16, "A_SF", "A"), p("AphB", Int16, "A_SF", "A"),
+			p("AphC", Int16, "A_SF", "A"), sf("A_SF"),
+			p("PhV", Int16, "V_SF", "V"), p("PhVphA", Int16, "V_SF", "V"), p("PhVphB", Int16, "V_SF", "V"),
+			p("PhVphC", Int16, "V_SF", "V"),
+			p("PPV", Int16, "V_SF", "V"), p("PPVphAB", Int16, "V_SF", "V"), p("PPVphBC", Int16, "V_SF", "V"),
+			p("PPVphCA", Int16, "V_SF", "V"), sf("V_SF"),
+			p("Hz", Int16, "Hz_SF", "Hz"), sf("Hz_SF"),
+			p("W", Int16, "W_SF", "W"), p("WphA", Int16, "W_SF", "W"), p("WphB", Int16, "W_SF", "W"),
+			p("WphC", Int16, "W_SF", "W"), sf("W_SF"),
+			p("VA", Int16, "VA_SF", "VA"), p("VAphA", Int16, "VA_SF", "VA"), p("VAphB", Int16, "VA_SF", "VA"),
+			p("VAphC", Int16, "VA_SF", "VA"), sf("VA_SF"),
+			p("VAR", Int16, "VAR_SF", "var"), p("VARphA", Int16, "VAR_SF", "var"),
+			p("VARphB", Int16, "VAR_SF", "var"), p("VARphC", Int16, "VAR_SF", "var"), sf("VAR_SF"),
+			p("PF", Int16, "PF_SF", "Pct"), p("PFphA", Int16, "PF_SF", "Pct"), p("PFphB", Int16, "PF_SF", "Pct"),
+			p("PFphC", Int16, "PF_SF", "Pct"), sf("PF_SF"),
+			p("TotWhExp", Acc32, "TotWh_SF", "Wh"), p("TotWhExpPhA", Acc32, "TotWh_SF", "Wh"),
+			p("TotWhExpPhB", Acc32, "TotWh_SF", "Wh"), p("TotWhExpPhC", Acc32, "TotWh_SF", "Wh"),
+			p("TotWhImp", Acc32, "TotWh_SF", "Wh"), p("TotWhImpPhA", Acc32, "TotWh_SF", "Wh"),
+			p("TotWhImpPhB", Acc32, "TotWh_SF", "Wh"), p("TotWhImpPhC", Acc32, "TotWh_SF", "Wh"), sf("TotWh_SF"),
+			p("TotVAhExp", Acc32, "TotVAh_SF", "VAh"), p("TotVAhExpPhA", Acc32, "TotVAh_SF", "VAh"),
+			p("TotVAhExpPhB", Acc32, "TotVAh_SF", "VAh"), p("TotVAhExpPhC", Acc32, "TotVAh_SF", "VAh"),
+			p("TotVAhImp", Acc32, "TotVAh_SF", "VAh"), p("TotVAhImpPhA", Acc32, "TotVAh_SF", "VAh"),
+			p("TotVAhImpPhB", Acc32, "TotVAh_SF", "VAh"), p("TotVAhImpPhC", Acc32, "TotVAh_SF", "VAh"),
+			sf("TotVAh_SF"),
+			p("TotVArhImpQ1", Acc32, "TotVArh_SF", "varh"), p("TotVArhImpQ1PhA", Acc32, "TotVArh_SF", "varh"),
+			p("TotVArhImpQ1PhB", Acc32, "TotVArh_SF", "varh"), p("TotVArhImpQ1PhC", Acc32, "TotVArh_SF", "varh"),
+			p("TotVArhImpQ2", Acc32, "TotVArh_SF", "varh"), p("TotVArhImpQ2PhA", Acc32, "TotVArh_SF", "varh"),
+			p("TotVArhImpQ2PhB", Acc32, "TotVArh_SF", "varh"), p("TotVArhImpQ2PhC", Acc32, "TotVArh_SF", "varh"),
+			p("TotVArhExpQ3", Acc32, "TotVArh_SF", "varh"), p("TotVArhExpQ3PhA", Acc32, "TotVArh_SF", "varh"),
+			p("TotVArhExpQ3PhB", Acc32, "TotVArh_SF", "varh"), p("TotVArhExpQ3PhC", Acc32, "TotVArh_SF", "varh"),
+			p("TotVArhExpQ4", Acc32, "TotVArh_SF", "varh"), p("TotVArhExpQ4PhA", Acc32, "TotVArh_SF", "varh"),
+			p("TotVArhExpQ4PhB", Acc32, "TotVArh_SF", "varh"), p("TotVArhExpQ4PhC", Acc32, "TotVArh_SF", "varh"),
+			sf("TotVArh_SF"),
+			p("Evt", Bitfield32, "", ""))
+	}
+
+	define(802, "Battery Base",
+		p("AHRtg", Uint16, "AHRtg_SF", "Ah"), p("WHRtg", Uint16, "WHRtg_SF", "Wh"),
+		p("WChaRteMax", Uint16, "WChaDisChaMax_SF", "W"), p("WDisChaRteMax", Uint16, "WChaDisChaMax_SF", "W"),
+		p("DisChaRte", Uint16, "DisChaRte_SF", "%WHRtg"),
+		p("SoCMax", Uint16, "SoC_SF", "%WHRtg"), p("SoCMin", Uint16, "SoC_SF", "%WHRtg"),
+		p("SocRsvMax", Uint16, "SoC_SF", "%WHRtg"), p("SoCRsvMin", Uint16, "SoC_SF", "%WHRtg"),
+		p("SoC", Uint16, "SoC_SF", "%WHRtg"), p("DoD", Uint16, "DoD_SF", "%"), p("SoH", Uint16, "SoH_SF", "%"),
+		p("NCyc", Uint32, "", ""), p("ChaSt", Enum16, "", ""), p("LocRemCtl", Enum16, "", ""),
+		p("Hb", Uint16, "", ""), p("CtrlHb", Uint16, "", ""), p("AlmRst", Uint16, "", ""),
+		p("Typ", Enum16, "", ""), p("State", Enum16, "", ""), p("StateVnd", Enum16, "", ""),
+		p("WarrDt", Uint32, "", ""),
+		p("Evt1", Bitfield32, "", ""), p("Evt2", Bitfield32, "", ""),
+		p("EvtVnd1", Bitfield32, "", ""), p("EvtVnd2", Bitfield32, "", ""),
+		p("V", Uint16, "V_SF", "V"), p("VMax", Uint16, "V_SF", "V"), p("VMin", Uint16, "V_SF", "V"),
+		p("CellVMax", Uint16, "CellV_SF", "V"), p("CellVMaxStr", Uint16, "", ""), p("CellVMaxMod", Uint16, "", ""),
+		p("CellVMin", Uint16, "CellV_SF", "V"), p("CellVMinStr", Uint16, "", ""), p("CellVMinMod", Uint16, "", ""),
+		p("CellVAvg", Uint16, "CellV_SF", "V"),
+		p("A", Int16, "A_SF", "A"), p("AChaMax", Uint16, "AMax_SF", "A"), p("ADisChaMax", Uint16, "AMax_SF", "A"),
+		p("W", Int16, "W_SF", "W"), p("ReqInvState", Enum16, "", ""), p("ReqW", Int16, "W_SF", "W"),
+		p("SetOp", Enum16, "", ""), p("SetInvState", Enum16, "", ""),
+		sf("AHRtg_SF"), sf("WHRtg_SF"), sf("WChaDisChaMax_SF"), sf("DisChaRte_SF"), sf("SoC_SF"), sf("DoD_SF"),
+		sf("SoH_SF"), sf("V_SF"), sf("CellV_SF"), sf("A_SF"), sf("AMax_SF"), sf("W_SF"))
+}
@@ -0,0 +1,298 @@
+// Package sunspec discovers and decodes the SunSpec models of a Modbus device
+package sunspec
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/simonvetter/modbus"
+)
+
+// BaseAddresses are the addresses at which the SunS marker is searched, in order
+var BaseAddresses = []uint16{40000, 0, 50000}
+
+const (
+	marker0 = 0x5375 // "Su"
+	marker1 = 0x6e53 // "nS"
+	// endID is the ID of the end model, it marks the end of the model chain
+	endID = 0xffff
+	// maxModels limits the walk of the model chain, so a device that does not end the chain is not read forever
+	maxModels = 100
+	// maxRegisters is the maximum number of registers that is read in a single request
+	maxRegisters = 125
+)
+
+// ErrNotSunSpec is returned when the SunS marker is not found at any of the base addresses
+var ErrNotSunSpec = errors.New("no SunSpec marker found")
+
+// ErrUnknownModel is returned when a model is read that has no definition
+var ErrUnknownModel = errors.New("unknown model")
+
+// Reader reads holding registers, *modbus.ModbusClient implements it
+type Reader interface {
+	ReadRegisters(address uint16, quantity uint16, regType modbus.RegType) ([]uint16, error)
+}
+
+// Header is the location of a model in the model chain
+type Header struct {
+	ID uint16
+	// Address is the address of the ID of the model, the points start at Address + 2
+	Address uint16
+	// Length is the number of registers of the model, without the ID and length
+	Length uint16
+}
+
+// Name returns the name of the model, or its ID when the model is unknown
+func (h Header) Name() string {
+	if model, ok := Models[h.ID]; ok {
+		return model.Name
+	}
+	return fmt.Sprintf("model %d", h.ID)
+}
+
+// Device holds the models of a device
+type Device struct {
+	Base   uint16
+	Models []Header
+}
+
+// Find returns the first model with one of the given IDs
+func (d *Device) Find(ids ...uint16) (Header, bool) {
+	for _, h := range d.Models {
+		for _, id := range ids {
+			if h.ID == id {
+				return h, true
+			}
+		}
+	}
+	return Header{}, false
+}
+
+// Common returns the common model (1) that describes the given model, the common model precedes the models of the
+// device it describes. A SolarEdge inverter has a common model for itself and one for each meter.
+func (d *Device) Common(h Header) (Header, bool) {
+	common, found := Header{}, false
+	for _, m := range d.Models {
+		if m == h {
+			return common, found
+		}
+		if m.ID == 1 {
+			common, found = m, true
+		}
+	}
+	return Header{}, false
+}
+
+// Discover finds the SunS marker and walks the model chain that follows it
+func Discover(r Reader) (*Device, error) {
+	for _, base := range BaseAddresses {
+		result, err := r.ReadRegisters(base, 2, modbus.HOLDING_REGISTER)
+		if errors.Is(err, modbus.ErrIllegalDataAddress) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if result[0] != marker0 || result[1] != marker1 {
+			continue
+		}
+		return walk(r, base)
+	}
+	return nil, ErrNotSunSpec
+}
+
+func walk(r Reader, base uint16) (*Device, error) {
+	device := &Device{Base: base}
+	address := base + 2
+	for len(device.Models) < maxModels {
+		result, err := r.ReadRegisters(address, 2, modbus.HOLDING_REGISTER)
+		// Some devices do not implement the end model, the chain ends at the first address that cannot be read
+		if errors.Is(err, modbus.ErrIllegalDataAddress) {
+			return device, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if result[0] == endID {
+			return device, nil
+		}
+		device.Models = append(device.Models, Header{ID: result[0], Address: address, Length: result[1]})
+		if uint32(address)+2+uint32(result[1]) > math.MaxUint16 {
+			return nil, fmt.Errorf("model %d at address %d runs past the last address", result[0], address)
+		}
+		address += 2 + result[1]
+	}
+	return nil, fmt.Errorf("the model chain at %d does not end after %d models", base, maxModels)
+}
+
+// Block holds the decoded points of a model
+type Block struct {
+	Header Header
+	Values map[string]Value
+	// Repeats holds the values of the repeating blocks of the model (e.g. the modules of an MPPT model)
+	Repeats []map[string]Value
+}
+
+// Value is a decoded point
+type Value struct {
+	Point Point
+	// Int is the value of an integer point, before it is scaled
+	Int int64
+	// Float is the value of a float point
+	Float float64
+	// Text is the value of a string point
+	Text string
+	// Scale is the scale factor of the value, the value is Int * 10^Scale
+	Scale int16
+	// Implemented is false when the device does not implement the point, its value is the "not implemented" value
+	Implemented bool
+}
+
+// Int64 returns the value multiplied by 10^scale, e.g. a scale of 3 returns a value in V as mV. A value that is not
+// implemented is returned as 0.
+func (v Value) Int64(scale int16) int64 {
+	if !v.Implemented {
+		return 0
+	}
+	if v.Point.Type == Float32 {
+		return int64(math.Round(v.Float * math.Pow10(int(scale))))
+	}
+	return scaleInt64(v.Int, v.Scale+scale)
+}
+
+// Int64 returns the value of the given point multiplied by 10^scale, see Value.Int64
+func (b *Block) Int64(name string, scale int16) int64 {
+	return b.Values[name].Int64(scale)
+}
+
+// Text returns the value of the given string point
+func (b *Block) Text(name string) string {
+	return b.Values[name].Text
+}
+
+// Read reads and decodes the model with the given header
+func Read(r Reader, h Header) (*Block, error) {
+	model, ok := Models[h.ID]
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownModel, h.ID)
+	}
+	if h.Length < model.Length {
+		return nil, fmt.Errorf("model %d has length %d, expected at least %d", h.ID, h.Length, model.Length)
+	}
+
+	registers := make([]uint16, 0, h.Length)
+	for address, remaining := h.Address+2, h.Length; remaining > 0; {
+		quantity := remaining
+		if quantity > maxRegisters {
+			quantity = maxRegisters
+		}
+		result, err := r.ReadRegisters(address, quantity, modbus.HOLDING_REGISTER)
+		if err != nil {
+			return nil, err
+		}
+		registers = append(registers, result...)
+		address += quantity
+		remaining -= quantity
+	}
+
+	return Decode(model, h, registers), nil
+}
+
+// Decode decodes the registers of a model, the registers start after the ID and length
+func Decode(model *Model, h Header, registers []uint16) *Block {
+	block := &Block{Header: h, Values: decodePoints(model.Points, registers, nil)}
+
+	if model.RepeatLength > 0 {
+		for offset := model.Length; offset+model.RepeatLength <= uint16(len(registers)); offset += model.RepeatLength {
+			repeat := decodePoints(model.Repeating, registers[offset:offset+model.RepeatLength], block.Values)
+			block.Repeats = append(block.Repeats, repeat)
+		}
+	}
+	return block
+}
+
+// decodePoints decodes the points, the scale factors are looked up in the points themselves or else in fixed (the
+// scale factors of a repeating block are part of the fixed block)
+func decodePoints(points []Point, registers []uint16, fixed map[string]Value) map[string]Value {
+	values := make(map[string]Value, len(points))
+	for _, p := range points {
+		if p.Type == Pad {
+			continue
+		}
+		values[p.Name] = decodePoint(p, registers[p.Offset:p.Offset+p.Size])
+	}
+
+	for name, v := range values {
+		if v.Point.ScaleFactor == "" {
+			continue
+		}
+		sf, ok := values[v.Point.ScaleFactor]
+		if !ok {
+			sf = fixed[v.Point.ScaleFactor]
+		}
+		// A value without a (valid) scale factor cannot be interpreted
+		if !sf.Implemented || sf.Int < -10 || sf.Int > 10 {
+			v.Implemented = false
+		}
+		v.Scale = int16(sf.Int)
+		values[name] = v
+	}
+	return values
+}
+
+func decodePoint(p Point, r []uint16) Value {
+	v := Value{Point: p, Implemented: true}
+	switch p.Type {
+	case Uint16, Enum16, Bitfield16:
+		v.Int = int64(r[0])
+		v.Implemented = r[0] != 0xffff
+	case Int16, ScaleFactor:
+		v.Int = int64(int16(r[0]))
+		v.Implemented = r[0] != 0x8000
+	case Uint32, Bitfield32:
+		u := uint32(r[0])<<16 | uint32(r[1])
+		v.Int = int64(u)
+		v.Implemented = u != 0xffffffff
+	case Int32:
+		u := uint32(r[0])<<16 | uint32(r[1])
+		v.Int = int64(int32(u))
+		v.Implemented = u != 0x80000000
+	case Acc32:
+		u := uint32(r[0])<<16 | uint32(r[1])
+		v.Int = int64(u)
+		v.Implemented = u != 0
+	case Acc64:
+		u := uint64(r[0])<<48 | uint64(r[1])<<32 | uint64(r[2])<<16 | uint64(r[3])
+		v.Int = int64(u)
+		v.Implemented = u != 0 && u <= math.MaxInt64
+	case Float32:
+		v.Float = float64(math.Float32frombits(uint32(r[0])<<16 | uint32(r[1])))
+		v.Implemented = !math.IsNaN(v.Float)
+	case String:
+		b := make([]byte, 0, 2*len(r))
+		for _, w := range r {
+			b = append(b, byte(w>>8), byte(w))
+		}
+		if i := strings.IndexByte(string(b), 0); i >= 0 {
+			b = b[:i]
+		}
+		v.Text = strings.TrimSpace(string(b))
+		v.Implemented = len(b) > 0
+	}
+	return v
+}
+
+func scaleInt64(number int64, scale int16) int64 {
+	if scale < 0 {
+		if -scale > 18 { // 10 ** 19 overflows
+			return 0
+		}
+		return number / int64(math.Pow10(int(-scale)))
+	}
+	if scale > 18 {
+		return math.MaxInt64
+	}
+	return number * int64(math.Pow10(int(scale)))
+}
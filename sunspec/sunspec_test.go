@@ -0,0 +1,222 @@
+package sunspec
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/simonvetter/modbus"
+)
+
+// registers is a device that holds the registers in a map, reading an address that is not in the map fails
+type registers map[uint16]uint16
+
+func (r registers) ReadRegisters(address uint16, quantity uint16, regType modbus.RegType) ([]uint16, error) {
+	result := make([]uint16, quantity)
+	for i := range result {
+		v, ok := r[address+uint16(i)]
+		if !ok {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		result[i] = v
+	}
+	return result, nil
+}
+
+// add adds a model with the given registers at address and returns the address of the next model
+func (r registers) add(address uint16, id uint16, values []uint16) uint16 {
+	r[address] = id
+	r[address+1] = uint16(len(values))
+	for i, v := range values {
+		r[address+2+uint16(i)] = v
+	}
+	return address + 2 + uint16(len(values))
+}
+
+func (r registers) marker(base uint16) uint16 {
+	r[base] = marker0
+	r[base+1] = marker1
+	return base + 2
+}
+
+func TestModelLengths(t *testing.T) {
+	lengths := map[uint16]uint16{
+		1: 65, 101: 50, 102: 50, 103: 50, 111: 60, 112: 60, 113: 60, 120: 26, 121: 30, 122: 44, 123: 24, 124: 24,
+		160: 8, 201: 105, 202: 105, 203: 105, 204: 105, 802: 62,
+	}
+	for id, length := range lengths {
+		if Models[id] == nil {
+			t.Errorf("model %d is not defined", id)
+			continue
+		}
+		if Models[id].Length != length {
+			t.Errorf("model %d has length %d, expected %d", id, Models[id].Length, length)
+		}
+	}
+	if Models[160].RepeatLength != 20 {
+		t.Errorf("the repeating block of model 160 has length %d, expected 20", Models[160].RepeatLength)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	for _, base := range BaseAddresses {
+		r := registers{}
+		address := r.marker(base)
+		address = r.add(address, 1, make([]uint16, 66))
+		address = r.add(address, 103, make([]uint16, 50))
+		address = r.add(address, 1, make([]uint16, 65))
+		address = r.add(address, 203, make([]uint16, 105))
+		r.add(address, endID, nil)
+
+		device, err := Discover(r)
+		if err != nil {
+			t.Fatalf("base %d: %v", base, err)
+		}
+		expected := []Header{
+			{1, base + 2, 66}, {103, base + 70, 50}, {1, base + 122, 65}, {203, base + 189, 105},
+		}
+		if device.Base != base || len(device.Models) != len(expected) {
+			t.Fatalf("base %d: unexpected device %+v", base, device)
+		}
+		for i, h := range expected {
+			if device.Models[i] != h {
+				t.Errorf("base %d: model %d is %+v, expected %+v", base, i, device.Models[i], h)
+			}
+		}
+		if h, ok := device.Find(201, 202, 203, 204); !ok || h.ID != 203 {
+			t.Errorf("base %d: meter not found", base)
+		}
+		if h, ok := device.Common(device.Models[3]); !ok || h != expected[2] {
+			t.Errorf("base %d: common model of the meter is %+v", base, h)
+		}
+		if h, ok := device.Common(device.Models[1]); !ok || h != expected[0] {
+			t.Errorf("base %d: common model of the inverter is %+v", base, h)
+		}
+	}
+}
+
+func TestDiscoverWithoutEndModel(t *testing.T) {
+	r := registers{}
+	r.add(r.marker(40000), 1, make([]uint16, 65))
+
+	device, err := Discover(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(device.Models) != 1 {
+		t.Errorf("expected 1 model, got %+v", device.Models)
+	}
+}
+
+func TestDiscoverNotSunSpec(t *testing.T) {
+	r := registers{40000: 1, 40001: 2}
+	if _, err := Discover(r); !errors.Is(err, ErrNotSunSpec) {
+		t.Errorf("expected ErrNotSunSpec, got %v", err)
+	}
+}
+
+func TestReadInverter(t *testing.T) {
+	values := make([]uint16, 50)
+	values[0] = 1234    // A
+	values[1] = 0xffff  // AphA not implemented
+	values[4] = 0xfffe  // A_SF = -2
+	values[8] = 2301    // PhVphA
+	values[11] = 0xffff // V_SF = -1
+	values[12] = 0xfc18 // W = -1000
+	values[13] = 0      // W_SF
+	values[22] = 0x0001 // WH
+	values[23] = 0x86a0 // WH = 100000
+	values[24] = 0x8000 // WH_SF not implemented
+	values[36] = 4      // St
+	values[37] = 0x0102 // StVnd
+	r := registers{}
+	r.add(40069, 103, values)
+
+	block, err := Read(r, Header{103, 40069, 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]int64{
+		"A": 12340, "AphA": 0, "PhVphA": 230100, "W": -1000, "WH": 0, "St": 4, "StVnd": 0x0102,
+	} {
+		scale := int16(3)
+		if name == "W" || name == "WH" || name == "St" || name == "StVnd" {
+			scale = 0
+		}
+		if v := block.Int64(name, scale); v != expected {
+			t.Errorf("%s is %d, expected %d", name, v, expected)
+		}
+	}
+}
+
+func TestReadFloatInverter(t *testing.T) {
+	values := make([]uint16, 60)
+	bits := math.Float32bits(12.5)
+	values[0], values[1] = uint16(bits>>16), uint16(bits)
+	bits = math.Float32bits(float32(math.NaN()))
+	values[2], values[3] = uint16(bits>>16), uint16(bits)
+	r := registers{}
+	r.add(0, 113, values)
+
+	block, err := Read(r, Header{113, 0, 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := block.Int64("A", 3); v != 12500 {
+		t.Errorf("A is %d, expected 12500", v)
+	}
+	if block.Values["AphA"].Implemented {
+		t.Errorf("AphA should not be implemented")
+	}
+}
+
+func TestReadRepeatingBlocks(t *testing.T) {
+	values := make([]uint16, 8+2*20)
+	values[0] = 0xfffe // DCA_SF = -2
+	values[6] = 2      // N
+	values[8+9] = 750  // DCA of module 1
+	values[28+9] = 500 // DCA of module 2
+	values[28+1] = 'P'<<8 | 'V'
+	r := registers{}
+	r.add(50000, 160, values)
+
+	block, err := Read(r, Header{160, 50000, uint16(len(values))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Repeats) != 2 {
+		t.Fatalf("expected 2 modules, got %d", len(block.Repeats))
+	}
+	if v := block.Repeats[0]["DCA"].Int64(3); v != 7500 {
+		t.Errorf("DCA of module 1 is %d, expected 7500", v)
+	}
+	if v := block.Repeats[1]["DCA"].Int64(3); v != 5000 {
+		t.Errorf("DCA of module 2 is %d, expected 5000", v)
+	}
+	if s := block.Repeats[1]["IDStr"].Text; s != "PV" {
+		t.Errorf("IDStr of module 2 is '%s', expected 'PV'", s)
+	}
+}
+
+func TestReadLongModel(t *testing.T) {
+	// Read splits models that are longer than a single request
+	values := make([]uint16, 8+10*20)
+	values[8+9*20+9] = 42
+	r := registers{}
+	r.add(40000, 160, values)
+
+	block, err := Read(r, Header{160, 40000, uint16(len(values))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Repeats) != 10 || block.Repeats[9]["DCA"].Int64(0) != 42 {
+		t.Errorf("unexpected modules %+v", block.Repeats)
+	}
+}
+
+func TestReadUnknownModel(t *testing.T) {
+	if _, err := Read(registers{}, Header{64000, 40000, 10}); !errors.Is(err, ErrUnknownModel) {
+		t.Errorf("expected ErrUnknownModel, got %v", err)
+	}
+}
@@ -24,12 +24,48 @@ type Telegram struct {
 	PowerDeliveryPhase1    int64     `json:"powerDeliveryPhase1,omitempty"`
 	PowerDeliveryPhase2    int64     `json:"powerDeliveryPhase2,omitempty"`
 	PowerDeliveryPhase3    int64     `json:"powerDeliveryPhase3,omitempty"`
+
+	// Instantaneous voltage, current and quality registers from the DSMR 5.0 companion standard
+	VoltagePhase1       int64               `json:"voltagePhase1,omitempty"` // mV
+	VoltagePhase2       int64               `json:"voltagePhase2,omitempty"` // mV
+	VoltagePhase3       int64               `json:"voltagePhase3,omitempty"` // mV
+	CurrentPhase1       int64               `json:"currentPhase1,omitempty"` // mA
+	CurrentPhase2       int64               `json:"currentPhase2,omitempty"` // mA
+	CurrentPhase3       int64               `json:"currentPhase3,omitempty"` // mA
+	Version             int8                `json:"version,omitempty"`
+	EquipmentIdentifier string              `json:"equipmentIdentifier,omitempty"`
+	PowerFailures       int64               `json:"powerFailures,omitempty"`
+	LongPowerFailures   int64               `json:"longPowerFailures,omitempty"`
+	PowerFailureLog     []PowerFailureEvent `json:"powerFailureLog,omitempty"`
+	VoltageSagsPhase1   int64               `json:"voltageSagsPhase1,omitempty"`
+	VoltageSagsPhase2   int64               `json:"voltageSagsPhase2,omitempty"`
+	VoltageSagsPhase3   int64               `json:"voltageSagsPhase3,omitempty"`
+	VoltageSwellsPhase1 int64               `json:"voltageSwellsPhase1,omitempty"`
+	VoltageSwellsPhase2 int64               `json:"voltageSwellsPhase2,omitempty"`
+	VoltageSwellsPhase3 int64               `json:"voltageSwellsPhase3,omitempty"`
+	TextMessage         string              `json:"textMessage,omitempty"`
+
+	// Devices connected to the M-Bus channels of the meter
+	MBus []MBusReading `json:"mbus,omitempty"`
+
+	// Belgian capacity tariff registers, only sent by e-MUCS meters
+	CapacityTariff *CapacityTariff `json:"capacityTariff,omitempty"`
+}
+
+// PowerFailureEvent is an entry of the power failure event log (1-0:99.97.0)
+type PowerFailureEvent struct {
+	End      time.Time `json:"end"`
+	Duration int64     `json:"duration"` // s
 }
 
 type TelegramHandler struct {
 	IMeasurementHandler[Telegram]
 }
 
+func (h TelegramHandler) Name() string {
+	return "p1"
+}
+
 func (h TelegramHandler) CreatePoint(m Telegram) *write.Point {
 	return influxdb2.NewPoint(
 		"metering",
@@ -50,6 +86,23 @@ func (h TelegramHandler) CreatePoint(m Telegram) *write.Point {
 			"powerDeliveryPhase1":    float64(m.PowerDeliveryPhase1),
 			"powerDeliveryPhase2":    float64(m.PowerDeliveryPhase2),
 			"powerDeliveryPhase3":    float64(m.PowerDeliveryPhase3),
+			"voltagePhase1":          float64(m.VoltagePhase1) / 1000.0,
+			"voltagePhase2":          float64(m.VoltagePhase2) / 1000.0,
+			"voltagePhase3":          float64(m.VoltagePhase3) / 1000.0,
+			"currentPhase1":          float64(m.CurrentPhase1) / 1000.0,
+			"currentPhase2":          float64(m.CurrentPhase2) / 1000.0,
+			"currentPhase3":          float64(m.CurrentPhase3) / 1000.0,
+			"version":                m.Version,
+			"equipmentIdentifier":    m.EquipmentIdentifier,
+			"powerFailures":          m.PowerFailures,
+			"longPowerFailures":      m.LongPowerFailures,
+			"voltageSagsPhase1":      m.VoltageSagsPhase1,
+			"voltageSagsPhase2":      m.VoltageSagsPhase2,
+			"voltageSagsPhase3":      m.VoltageSagsPhase3,
+			"voltageSwellsPhase1":    m.VoltageSwellsPhase1,
+			"voltageSwellsPhase2":    m.VoltageSwellsPhase2,
+			"voltageSwellsPhase3":    m.VoltageSwellsPhase3,
+			"textMessage":            m.TextMessage,
 		},
 		m.Timestamp,
 	)
@@ -102,6 +155,81 @@ func (h TelegramHandler) WriteMeasurement(writer io.Writer, telegram Telegram, p
 	if err = WriteValue(writer, telegram.PowerDeliveryPhase3, previousTelegram.PowerDeliveryPhase3); err != nil {
 		return
 	}
+	if err = WriteValue(writer, telegram.VoltagePhase1, previousTelegram.VoltagePhase1); err != nil {
+		return
+	}
+	if err = WriteValue(writer, telegram.VoltagePhase2, previousTelegram.VoltagePhase2); err != nil {
+		return
+	}
+	if err = WriteValue(writer, telegram.VoltagePhase3, previousTelegram.VoltagePhase3); err != nil {
+		return
+	}
+	if err = WriteValue(writer, telegram.CurrentPhase1, previousTelegram.CurrentPhase1); err != nil {
+		return
+	}
+	if err = WriteValue(writer, telegram.CurrentPhase2, previousTelegram.CurrentPhase2); err != nil {
+		return
+	}
+	if err = WriteValue(writer, telegram.CurrentPhase3, previousTelegram.CurrentPhase3); err != nil {
+		return
+	}
+	if err = WriteValue(writer, int64(telegram.Version), int64(previousTelegram.Version)); err != nil {
+		return
+	}
+	if err = WriteString(writer, telegram.EquipmentIdentifier, previousTelegram.EquipmentIdentifier); err != nil {
+		return
+	}
+	if err = WriteValue(writer, telegram.PowerFailures, previousTelegram.PowerFailures); err != nil {
+		return
+	}
+	if err = WriteValue(writer, telegram.LongPowerFailures, previousTelegram.LongPowerFailures); err != nil {
+		return
+	}
+	if err = writePowerFailureLog(writer, telegram.PowerFailureLog, previousTelegram.PowerFailureLog); err != nil {
+		return
+	}
+	if err = WriteValue(writer, telegram.VoltageSagsPhase1, previousTelegram.VoltageSagsPhase1); err != nil {
+		return
+	}
+	if err = WriteValue(writer, telegram.VoltageSagsPhase2, previousTelegram.VoltageSagsPhase2); err != nil {
+		return
+	}
+	if err = WriteValue(writer, telegram.VoltageSagsPhase3, previousTelegram.VoltageSagsPhase3); err != nil {
+		return
+	}
+	if err = WriteValue(writer, telegram.VoltageSwellsPhase1, previousTelegram.VoltageSwellsPhase1); err != nil {
+		return
+	}
+	if err = WriteValue(writer, telegram.VoltageSwellsPhase2, previousTelegram.VoltageSwellsPhase2); err != nil {
+		return
+	}
+	if err = WriteValue(writer, telegram.VoltageSwellsPhase3, previousTelegram.VoltageSwellsPhase3); err != nil {
+		return
+	}
+	if err = WriteString(writer, telegram.TextMessage, previousTelegram.TextMessage); err != nil {
+		return
+	}
+	return nil
+}
+
+// writePowerFailureLog writes the number of entries in the log followed by the end timestamp and duration of every
+// entry. The values are written relative to the entry at the same position in the previous log.
+func writePowerFailureLog(writer io.Writer, events []PowerFailureEvent, previousEvents []PowerFailureEvent) error {
+	if err := WriteValue(writer, int64(len(events)), int64(len(previousEvents))); err != nil {
+		return err
+	}
+	for i, event := range events {
+		previous := PowerFailureEvent{End: time.Unix(0, 0)}
+		if i < len(previousEvents) {
+			previous = previousEvents[i]
+		}
+		if err := WriteValue(writer, event.End.Unix(), previous.End.Unix()); err != nil {
+			return err
+		}
+		if err := WriteValue(writer, event.Duration, previous.Duration); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,183 @@
+// Package zeroexport limits the production of an inverter so the power that is delivered to the grid, as measured by the
+// smart meter, stays below a limit
+package zeroexport
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Source is the source of the commands of the controller in the audit log of the limiter
+const Source = "zero-export"
+
+const (
+	DefaultHysteresis    = 100
+	DefaultMinInterval   = 5 * time.Second
+	DefaultMaxStep       = 10
+	DefaultStaleTimeout  = 30 * time.Second
+	DefaultLimitDuration = 5 * time.Minute
+)
+
+// Limiter sets the active power limit of the inverter, *powercontrol.Limiter implements it
+type Limiter interface {
+	Set(source string, limit uint16, duration time.Duration) error
+}
+
+// Config configures the controller, the powers are in W
+type Config struct {
+	// ExportLimit is the maximum power that is delivered to the grid
+	ExportLimit int64
+	// NominalPower is the power of the inverter at a limit of 100 percent
+	NominalPower int64
+	// Hysteresis is the margin below the export limit in which the limit is not raised, it keeps the limit from
+	// oscillating around the export limit
+	Hysteresis int64
+	// MinInterval is the minimum time between two attempts to change the limit
+	MinInterval time.Duration
+	// MaxStep is the maximum increase of the limit in percent per change, the limit is lowered at once
+	MaxStep uint16
+	// StaleTimeout is the time without a reading after which the failsafe limit is set
+	StaleTimeout time.Duration
+	// FailsafeLimit is the limit in percent while there are no readings
+	FailsafeLimit uint16
+	// LimitDuration is the duration of every limit, the limit is renewed before half of it has passed. The watchdog
+	// of the limiter that reverts the limit runs in the same process, so when the process crashes the inverter keeps
+	// the last limit.
+	LimitDuration time.Duration
+}
+
+// DefaultConfig returns the default configuration for an inverter with the given nominal power, that keeps the export
+// at 0 W
+func DefaultConfig(nominalPower int64) Config {
+	return Config{
+		NominalPower:  nominalPower,
+		Hysteresis:    DefaultHysteresis,
+		MinInterval:   DefaultMinInterval,
+		MaxStep:       DefaultMaxStep,
+		StaleTimeout:  DefaultStaleTimeout,
+		LimitDuration: DefaultLimitDuration,
+	}
+}
+
+// Controller adjusts the limit of the inverter to the readings of the smart meter. It is not safe for concurrent use.
+type Controller struct {
+	Config
+	limiter Limiter
+
+	// limit is the last limit that is set, it is 100 until the first limit is set
+	limit       uint16
+	lastSet     time.Time
+	lastAttempt time.Time
+	lastReading time.Time
+	stale       bool
+}
+
+// NewController creates a controller of the limiter, it starts without a limit
+func NewController(config Config, limiter Limiter) *Controller {
+	return &Controller{Config: config, limiter: limiter, limit: 100}
+}
+
+// Limit returns the last limit that is set
+func (c *Controller) Limit() uint16 {
+	return c.limit
+}
+
+// Update handles a reading: export is the power that is delivered to the grid (negative when power is consumed from
+// the grid) and production the current power of the inverter
+func (c *Controller) Update(now time.Time, export int64, production int64) {
+	c.lastReading = now
+	if c.stale {
+		log.Infof("Readings are received again, the export is %d W", export)
+		c.stale = false
+	}
+
+	excess := export - c.ExportLimit
+	target := c.limit
+	switch {
+	case excess > 0:
+		// Lower the production by the excess
+		target = min(c.percentage(production-excess), c.limit)
+	case excess < -c.Hysteresis && c.limit < 100:
+		// Raise the production to the export limit, but not by more than the maximum step to give the inverter and
+		// the meter the time to follow
+		target = max(c.percentage(production-excess), c.limit)
+		if target > c.limit+c.MaxStep {
+			target = c.limit + c.MaxStep
+		}
+	}
+
+	if (target != c.limit && now.Sub(c.lastAttempt) >= c.MinInterval) || c.needsRenewal(now) {
+		c.set(now, target)
+	}
+}
+
+// Check sets the failsafe limit when no reading is received within the stale timeout and renews the limit, it must be
+// called periodically
+func (c *Controller) Check(now time.Time) {
+	// Until the first reading the stale timeout starts at the first check, so the failsafe is also set when the meter
+	// is never read, e.g. because the cable is not connected
+	if c.lastReading.IsZero() {
+		c.lastReading = now
+	}
+	if now.Sub(c.lastReading) > c.StaleTimeout {
+		if !c.stale {
+			log.Warnf("No readings since %s, setting the failsafe limit of %d%%", c.lastReading, c.FailsafeLimit)
+			c.stale = true
+		}
+		if (c.limit != c.FailsafeLimit && now.Sub(c.lastAttempt) >= c.MinInterval) || c.needsRenewal(now) {
+			c.set(now, c.FailsafeLimit)
+		}
+		return
+	}
+	if c.needsRenewal(now) {
+		c.set(now, c.limit)
+	}
+}
+
+// needsRenewal returns whether the limit must be renewed to keep the watchdog of the limiter from reverting it, a
+// failed renewal is retried after the minimum interval
+func (c *Controller) needsRenewal(now time.Time) bool {
+	return c.limit < 100 && now.Sub(c.lastSet) >= c.LimitDuration/2 && now.Sub(c.lastAttempt) >= c.MinInterval
+}
+
+// set sets the limit, a failed attempt counts for the minimum interval as well so a failing inverter is not asked with
+// every reading
+func (c *Controller) set(now time.Time, limit uint16) {
+	c.lastAttempt = now
+	if err := c.limiter.Set(Source, limit, c.LimitDuration); err != nil {
+		log.Errorf("Could not set the limit to %d%%: %v", limit, err)
+		return
+	}
+	if limit != c.limit {
+		log.Debugf("Changed the limit from %d%% to %d%%", c.limit, limit)
+	}
+	c.limit = limit
+	c.lastSet = now
+}
+
+// percentage returns the limit in percent for the power, it is rounded down to stay below the export limit
+func (c *Controller) percentage(power int64) uint16 {
+	if power <= 0 || c.NominalPower <= 0 {
+		return 0
+	}
+	p := power * 100 / c.NominalPower
+	if p > 100 {
+		return 100
+	}
+	return uint16(p)
+}
+
+func min(a, b uint16) uint16 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b uint16) uint16 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,143 @@
+package zeroexport
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// limiter records the limits that are set
+type limiter struct {
+	limits []uint16
+	err    error
+}
+
+func (l *limiter) Set(source string, limit uint16, duration time.Duration) error {
+	if l.err != nil {
+		return l.err
+	}
+	l.limits = append(l.limits, limit)
+	return nil
+}
+
+func TestController(t *testing.T) {
+	l := &limiter{}
+	c := NewController(DefaultConfig(5000), l)
+	t0 := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return t0.Add(d) }
+
+	for _, step := range []struct {
+		at         time.Duration
+		export     int64
+		production int64
+		limit      uint16
+		sets       int
+	}{
+		// Importing without a limit does nothing
+		{0, -500, 1000, 100, 0},
+		// 1000 W too much export, the inverter must produce 2000 W of its 5000 W
+		{1 * time.Second, 1000, 3000, 40, 1},
+		// The inverter is following the limit
+		{2 * time.Second, 500, 2500, 40, 1},
+		// Still 300 W too much, but within the minimum interval
+		{3 * time.Second, 300, 2000, 40, 1},
+		{7 * time.Second, 300, 2000, 34, 2},
+		// Within the hysteresis
+		{13 * time.Second, -50, 1700, 34, 2},
+		// Importing 1000 W, the limit is raised by at most 10%
+		{19 * time.Second, -1000, 1700, 44, 3},
+		// The limit is raised up to no limit
+		{25 * time.Second, -3000, 2200, 54, 4},
+		{31 * time.Second, -3000, 2700, 64, 5},
+		{37 * time.Second, -3000, 3200, 74, 6},
+		{43 * time.Second, -3000, 3700, 84, 7},
+		{49 * time.Second, -3000, 4200, 94, 8},
+		{55 * time.Second, -3000, 4700, 100, 9},
+		// Without a limit there is nothing to raise
+		{61 * time.Second, -3000, 4700, 100, 9},
+	} {
+		c.Update(at(step.at), step.export, step.production)
+		if c.Limit() != step.limit || len(l.limits) != step.sets {
+			t.Errorf("at %s: limit %d after %d sets, expected %d after %d sets", step.at, c.Limit(), len(l.limits),
+				step.limit, step.sets)
+		}
+	}
+}
+
+func TestRenewal(t *testing.T) {
+	l := &limiter{}
+	c := NewController(DefaultConfig(5000), l)
+	t0 := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	c.Update(t0, 2500, 5000)
+	if c.Limit() != 50 {
+		t.Fatalf("limit is %d, expected 50", c.Limit())
+	}
+
+	// The export is at the limit, so the limit is only renewed
+	for d := 10 * time.Second; d < DefaultLimitDuration/2; d += 10 * time.Second {
+		c.Update(t0.Add(d), 0, 2500)
+		c.Check(t0.Add(d))
+	}
+	if len(l.limits) != 1 {
+		t.Errorf("expected no renewal yet, got %v", l.limits)
+	}
+	c.Update(t0.Add(DefaultLimitDuration/2), 0, 2500)
+	if len(l.limits) != 2 || l.limits[1] != 50 {
+		t.Errorf("expected a renewal, got %v", l.limits)
+	}
+
+	// A failed renewal is retried after the minimum interval
+	l.err = errors.New("timeout")
+	c.Update(t0.Add(DefaultLimitDuration), 0, 2500)
+	l.err = nil
+	c.Update(t0.Add(DefaultLimitDuration+time.Second), 0, 2500)
+	if len(l.limits) != 2 {
+		t.Errorf("expected no retry within the minimum interval, got %v", l.limits)
+	}
+	c.Update(t0.Add(DefaultLimitDuration+DefaultMinInterval), 0, 2500)
+	if len(l.limits) != 3 {
+		t.Errorf("expected a retry, got %v", l.limits)
+	}
+}
+
+func TestFailsafe(t *testing.T) {
+	l := &limiter{}
+	config := DefaultConfig(5000)
+	config.FailsafeLimit = 20
+	c := NewController(config, l)
+	t0 := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	c.Update(t0, -500, 1000)
+	c.Check(t0.Add(DefaultStaleTimeout))
+	if len(l.limits) != 0 {
+		t.Errorf("expected no limit within the stale timeout, got %v", l.limits)
+	}
+	c.Check(t0.Add(DefaultStaleTimeout + time.Second))
+	if c.Limit() != 20 {
+		t.Errorf("limit is %d, expected the failsafe limit", c.Limit())
+	}
+
+	// When the readings return the limit is raised again
+	c.Update(t0.Add(DefaultStaleTimeout+10*time.Second), -2000, 1000)
+	if c.Limit() != 30 {
+		t.Errorf("limit is %d, expected 30", c.Limit())
+	}
+}
+
+func TestFailsafeWithoutReadings(t *testing.T) {
+	l := &limiter{}
+	c := NewController(DefaultConfig(5000), l)
+	t0 := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for d := time.Duration(0); d <= DefaultStaleTimeout; d += time.Second {
+		c.Check(t0.Add(d))
+	}
+	if len(l.limits) != 0 {
+		t.Errorf("expected no limit within the stale timeout after the start, got %v", l.limits)
+	}
+	c.Check(t0.Add(DefaultStaleTimeout + time.Second))
+	if c.Limit() != 0 || len(l.limits) != 1 {
+		t.Errorf("limit is %d after %v, expected the failsafe limit", c.Limit(), l.limits)
+	}
+}